@@ -20,6 +20,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	timeutil "github.com/djui/pkg/time"
 )
 
 var (
@@ -182,6 +184,12 @@ func (f *Future) GetNow(valueIfAbsent interface{}) (interface{}, error) {
 // GetWithTimeout waits if necessary for at most the given time for the
 // computation to complete, and then retrieves its value, if available.
 func (f *Future) GetWithTimeout(d time.Duration) (interface{}, error) {
+	return f.GetWithTimeoutClock(d, timeutil.RealClock{})
+}
+
+// GetWithTimeoutClock behaves just like GetWithTimeout except that the timeout
+// is measured using a given clock.
+func (f *Future) GetWithTimeoutClock(d time.Duration, clock timeutil.Clock) (interface{}, error) {
 	f.mu.Lock()
 	if f.canceled {
 		defer f.mu.Unlock()
@@ -198,7 +206,7 @@ func (f *Future) GetWithTimeout(d time.Duration) (interface{}, error) {
 		f.mu.Lock()
 		defer f.mu.Unlock()
 		return f.val, f.err
-	case <-time.After(d):
+	case <-clock.After(d):
 		return nil, ErrTimeout
 	}
 }
@@ -7,6 +7,8 @@ import (
 	"runtime"
 	"testing"
 	"time"
+
+	timeutil "github.com/djui/pkg/time"
 )
 
 func TestNew(t *testing.T) {
@@ -178,6 +180,24 @@ func TestGetNowWithTimeout(t *testing.T) {
 	assertEqual(t, "done", v)
 }
 
+func TestGetNowWithTimeoutClock(t *testing.T) {
+	c := make(chan struct{})
+	defer close(c)
+	task := func(...interface{}) (interface{}, error) {
+		<-c
+		return "done", nil
+	}
+	f := New(task)
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	go func() {
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+	}()
+	v, err := f.GetWithTimeoutClock(time.Hour, clock)
+	assertEqual(t, ErrTimeout, err)
+	assertEqual(t, nil, v)
+}
+
 func TestThenComposeSuccees(t *testing.T) {
 	taskA := func(...interface{}) (interface{}, error) {
 		return "done", nil
@@ -0,0 +1,153 @@
+package licensekey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"time"
+)
+
+// NewCA creates a self-signed certificate authority certificate for a given
+// RSA private key, valid from now on for the given duration.
+func NewCA(key *rsa.PrivateKey, commonName string, validFor time.Duration) (*x509.Certificate, error) {
+	template, err := newTemplate(&key.PublicKey, commonName, validFor)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.MaxPathLenZero = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	return createCertificate(template, template, &key.PublicKey, key)
+}
+
+// IssueCertificate issues a leaf certificate for a given RSA public key signed
+// by a certificate authority. Hosts may be DNS names or IP addresses and are
+// added as subject alternative names. The certificate can be used for both
+// server and client authentication.
+func IssueCertificate(ca *x509.Certificate, caKey *rsa.PrivateKey, key *rsa.PublicKey, commonName string, hosts []string, validFor time.Duration) (*x509.Certificate, error) {
+	template, err := newTemplate(key, commonName, validFor)
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	return createCertificate(template, ca, key, caKey)
+}
+
+// StoreCertificates stores certificates as PEM bundle on disk.
+func StoreCertificates(path string, certs ...*x509.Certificate) error {
+	return ioutil.WriteFile(path, CertificatesToBytes(certs...), 0644)
+}
+
+// LoadCertificates loads certificates as PEM bundle from disk.
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return CertificatesFromBytes(data)
+}
+
+// CertificatesToBytes encodes certificates as PEM bundle.
+func CertificatesToBytes(certs ...*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// CertificatesFromBytes parses certificates as PEM bundle from bytes.
+func CertificatesFromBytes(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("certificate can't be decoded: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no valid PEM data found")
+	}
+	return certs, nil
+}
+
+// MutualTLSConfig returns a TLS configuration presenting a given certificate
+// and requiring the peer to present a certificate issued by the given
+// certificate authority. It is suitable for both, servers and clients.
+func MutualTLSConfig(cert *x509.Certificate, key *rsa.PrivateKey, ca *x509.Certificate) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{cert.Raw},
+			PrivateKey:  key,
+			Leaf:        cert,
+		}},
+		RootCAs:    pool,
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+func newTemplate(key *rsa.PublicKey, commonName string, validFor time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyID := sha1.Sum(der)
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(validFor),
+		SubjectKeyId: keyID[:],
+	}, nil
+}
+
+func createCertificate(template, parent *x509.Certificate, pub *rsa.PublicKey, priv *rsa.PrivateKey) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(der)
+}
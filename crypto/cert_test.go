@@ -0,0 +1,61 @@
+package licensekey
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIssueCertificate(t *testing.T) {
+	caKey, err := GenerateKeys(2048)
+	assertNoError(t, err)
+	ca, err := NewCA(caKey, "Test CA", time.Hour)
+	assertNoError(t, err)
+
+	leafKey, err := GenerateKeys(2048)
+	assertNoError(t, err)
+	leaf, err := IssueCertificate(ca, caKey, &leafKey.PublicKey, "localhost",
+		[]string{"localhost", "127.0.0.1"}, time.Hour)
+	assertNoError(t, err)
+
+	tmpfile, _ := ioutil.TempFile("", "test")
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+	assertNoError(t, StoreCertificates(tmpfile.Name(), leaf, ca))
+	certs, err := LoadCertificates(tmpfile.Name())
+	assertNoError(t, err)
+	if len(certs) != 2 || !certs[0].Equal(leaf) || !certs[1].Equal(ca) {
+		t.Fatalf("unexpected bundle: %v", certs)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		_, err = leaf.Verify(x509.VerifyOptions{
+			DNSName:   host,
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		assertNoError(t, err)
+	}
+
+	server := MutualTLSConfig(leaf, leafKey, ca)
+	client := MutualTLSConfig(leaf, leafKey, ca)
+	client.ServerName = "localhost"
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	assertNoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	assertNoError(t, err)
+	conn.Close()
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrExpired indicates the exp claim lies in the past.
+	ErrExpired = errors.New("token is expired")
+	// ErrNotYetValid indicates the nbf claim lies in the future.
+	ErrNotYetValid = errors.New("token is not valid yet")
+	// ErrIssuedInFuture indicates the iat claim lies in the future.
+	ErrIssuedInFuture = errors.New("token is issued in the future")
+	// ErrInvalidIssuer indicates the iss claim does not match.
+	ErrInvalidIssuer = errors.New("invalid issuer")
+	// ErrInvalidAudience indicates the aud claim does not match.
+	ErrInvalidAudience = errors.New("invalid audience")
+)
+
+// Claims holds the registered claim names of RFC 7519, section 4.1. Embed it
+// into a struct to add private claims.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// Audience holds the aud claim, which may be a single string or an array of
+// strings.
+type Audience []string
+
+// MarshalJSON implements the json.Marshaler interface.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return err
+	}
+	*a = Audience(ss)
+	return nil
+}
+
+// Contains checks if the audience contains a given recipient.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Validator validates registered claims.
+type Validator struct {
+	// Issuer, if set, must equal the iss claim.
+	Issuer string
+	// Audience, if set, must be contained in the aud claim.
+	Audience string
+	// Leeway is the allowed clock skew for exp, nbf and iat.
+	Leeway time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Validate checks claims against the current time and the configured issuer
+// and audience. Time based claims which are unset are not checked.
+func (v Validator) Validate(c *Claims) error {
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	t := now()
+
+	if c.ExpiresAt != 0 && !t.Before(time.Unix(c.ExpiresAt, 0).Add(v.Leeway)) {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && t.Add(v.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrNotYetValid
+	}
+	if c.IssuedAt != 0 && t.Add(v.Leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return ErrIssuedInFuture
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return ErrInvalidIssuer
+	}
+	if v.Audience != "" && !c.Audience.Contains(v.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
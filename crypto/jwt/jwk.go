@@ -0,0 +1,156 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a public JSON Web Key as defined in RFC 7517 and RFC 8037.
+type JWK struct {
+	KeyType   string    `json:"kty"`
+	KeyID     string    `json:"kid,omitempty"`
+	Use       string    `json:"use,omitempty"`
+	Algorithm Algorithm `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK creates a signature verification JWK from a public key. Supported key
+// types are *rsa.PublicKey, *ecdsa.PublicKey on P-256 and ed25519.PublicKey.
+func NewJWK(key crypto.PublicKey, kid string, alg Algorithm) (JWK, error) {
+	jwk := JWK{KeyID: kid, Use: "sig", Algorithm: alg}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encoding.EncodeToString(k.N.Bytes())
+		jwk.E = encoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return JWK{}, fmt.Errorf("unsupported curve: %s", k.Curve.Params().Name)
+		}
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+		jwk.KeyType = "EC"
+		jwk.Curve = "P-256"
+		jwk.X = encoding.EncodeToString(x)
+		jwk.Y = encoding.EncodeToString(y)
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encoding.EncodeToString(k)
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type: %T", key)
+	}
+
+	return jwk, nil
+}
+
+// PublicKey returns the public key described by the JWK.
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := encoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %s", err)
+		}
+		e, err := encoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent: %q", jwk.E)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %q", jwk.Curve)
+		}
+		x, err := encoding.DecodeString(jwk.X)
+		if err != nil || len(x) != 32 {
+			return nil, fmt.Errorf("invalid x coordinate: %q", jwk.X)
+		}
+		y, err := encoding.DecodeString(jwk.Y)
+		if err != nil || len(y) != 32 {
+			return nil, fmt.Errorf("invalid y coordinate: %q", jwk.Y)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %q", jwk.Curve)
+		}
+		x, err := encoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key: %q", jwk.X)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %q", jwk.KeyType)
+}
+
+// ParseJWKS parses a JSON encoded JWK set.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+// Key returns the JWK with a given key ID.
+func (set *JWKS) Key(kid string) (JWK, bool) {
+	for _, jwk := range set.Keys {
+		if jwk.KeyID == kid {
+			return jwk, true
+		}
+	}
+	return JWK{}, false
+}
+
+// KeyFunc returns a KeyFunc selecting keys from the set by the kid header. If
+// the token has no kid and the set holds a single key, that key is used.
+func (set *JWKS) KeyFunc() KeyFunc {
+	return func(h *Header) (crypto.PublicKey, error) {
+		jwk, ok := set.Key(h.KeyID)
+		if !ok && h.KeyID == "" && len(set.Keys) == 1 {
+			jwk, ok = set.Keys[0], true
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %q", h.KeyID)
+		}
+		if jwk.Algorithm != "" && jwk.Algorithm != h.Algorithm {
+			return nil, ErrInvalidKey
+		}
+		return jwk.PublicKey()
+	}
+}
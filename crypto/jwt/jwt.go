@@ -0,0 +1,232 @@
+// Package jwt implements compact JSON Web Signatures (RFC 7515) and JSON Web
+// Tokens (RFC 7519) for the RS256, PS256, ES256 and EdDSA algorithms.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Algorithm identifies a JWS signature algorithm.
+type Algorithm string
+
+// Supported signature algorithms.
+const (
+	RS256 Algorithm = "RS256" // RSASSA-PKCS1-v1_5 using SHA-256
+	PS256 Algorithm = "PS256" // RSASSA-PSS using SHA-256 and MGF1 with SHA-256
+	ES256 Algorithm = "ES256" // ECDSA using P-256 and SHA-256
+	EdDSA Algorithm = "EdDSA" // Edwards-curve digital signature using Ed25519
+)
+
+var (
+	// ErrMalformed indicates a token is not in JWS compact serialization.
+	ErrMalformed = errors.New("malformed token")
+	// ErrUnsupportedAlgorithm indicates an unknown or disallowed algorithm.
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+	// ErrInvalidKey indicates a key not matching the algorithm.
+	ErrInvalidKey = errors.New("invalid key for algorithm")
+	// ErrInvalidSignature indicates a signature verification failure.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Header is the JOSE header of a token.
+type Header struct {
+	Algorithm Algorithm `json:"alg"`
+	Type      string    `json:"typ,omitempty"`
+	KeyID     string    `json:"kid,omitempty"`
+}
+
+// KeyFunc returns the public key to verify a token with, given its header. It
+// allows selecting keys by key ID, e.g. from a JWKS.
+type KeyFunc func(*Header) (crypto.PublicKey, error)
+
+// Sign encodes claims as JSON and signs them using a given algorithm and
+// private key, returning a token in JWS compact serialization. The private key
+// must be a *rsa.PrivateKey for RS256 and PS256, a *ecdsa.PrivateKey for ES256
+// and an ed25519.PrivateKey for EdDSA. An optional key ID is set as kid header.
+func Sign(alg Algorithm, key crypto.PrivateKey, kid string, claims interface{}) (string, error) {
+	header, err := json.Marshal(Header{Algorithm: alg, Type: "JWT", KeyID: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(header) + "." + encoding.EncodeToString(payload)
+	sig, err := sign(alg, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encoding.EncodeToString(sig), nil
+}
+
+// Parse verifies a token in JWS compact serialization using the key returned
+// by keyFunc and decodes its payload into claims. Only the given algorithms
+// are accepted; if none are given, all supported algorithms are. Parse does
+// not validate claims, use Validator for that.
+func Parse(token string, keyFunc KeyFunc, claims interface{}, algs ...Algorithm) (*Header, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	rawHeader, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var header Header
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, ErrMalformed
+	}
+	if !allowed(header.Algorithm, algs) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	key, err := keyFunc(&header)
+	if err != nil {
+		return nil, err
+	}
+	signingInput := token[:len(parts[0])+1+len(parts[1])]
+	if err := verify(header.Algorithm, key, []byte(signingInput), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("claims can't be decoded: %s", err)
+	}
+
+	return &header, nil
+}
+
+func allowed(alg Algorithm, algs []Algorithm) bool {
+	switch alg {
+	case RS256, PS256, ES256, EdDSA:
+	default:
+		return false
+	}
+	if len(algs) == 0 {
+		return true
+	}
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func sign(alg Algorithm, key crypto.PrivateKey, msg []byte) ([]byte, error) {
+	hashed := sha256.Sum256(msg)
+
+	switch alg {
+	case RS256:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hashed[:])
+	case PS256:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+		return rsa.SignPSS(rand.Reader, k, crypto.SHA256, hashed[:], opts)
+	case ES256:
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return nil, ErrInvalidKey
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, hashed[:])
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	case EdDSA:
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		return ed25519.Sign(k, msg), nil
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func verify(alg Algorithm, key crypto.PublicKey, msg, sig []byte) error {
+	hashed := sha256.Sum256(msg)
+
+	switch alg {
+	case RS256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidKey
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed[:], sig) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case PS256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidKey
+		}
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+		if rsa.VerifyPSS(k, crypto.SHA256, hashed[:], sig, opts) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case ES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return ErrInvalidKey
+		}
+		if len(sig) != 64 {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, hashed[:], r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case EdDSA:
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrInvalidKey
+		}
+		if !ed25519.Verify(k, msg, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	return ErrUnsupportedAlgorithm
+}
@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSignParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assertNoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assertNoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assertNoError(t, err)
+
+	tests := []struct {
+		alg Algorithm
+		key crypto.PrivateKey
+		pub crypto.PublicKey
+	}{
+		{RS256, rsaKey, &rsaKey.PublicKey},
+		{PS256, rsaKey, &rsaKey.PublicKey},
+		{ES256, ecKey, &ecKey.PublicKey},
+		{EdDSA, edKey, edPub},
+	}
+
+	for _, tt := range tests {
+		token, err := Sign(tt.alg, tt.key, "k1", Claims{Subject: "alice", Audience: Audience{"api"}})
+		assertNoError(t, err)
+
+		jwk, err := NewJWK(tt.pub, "k1", tt.alg)
+		assertNoError(t, err)
+		data, err := json.Marshal(JWKS{Keys: []JWK{jwk}})
+		assertNoError(t, err)
+		set, err := ParseJWKS(data)
+		assertNoError(t, err)
+
+		var claims Claims
+		header, err := Parse(token, set.KeyFunc(), &claims)
+		assertNoError(t, err)
+		if header.Algorithm != tt.alg || claims.Subject != "alice" || !claims.Audience.Contains("api") {
+			t.Fatalf("%s: unexpected result %+v %+v", tt.alg, header, claims)
+		}
+
+		tampered := token[:len(token)-4] + "AAAA"
+		if _, err := Parse(tampered, set.KeyFunc(), &claims); err != ErrInvalidSignature {
+			t.Fatalf("%s: %v != %v", tt.alg, ErrInvalidSignature, err)
+		}
+		if _, err := Parse(token, set.KeyFunc(), &claims, RS256); tt.alg != RS256 && err != ErrUnsupportedAlgorithm {
+			t.Fatalf("%s: %v != %v", tt.alg, ErrUnsupportedAlgorithm, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	v := Validator{
+		Issuer:   "iss",
+		Audience: "aud",
+		Leeway:   10 * time.Second,
+		Now:      func() time.Time { return now },
+	}
+
+	tests := []struct {
+		claims Claims
+		err    error
+	}{
+		{Claims{Issuer: "iss", Audience: Audience{"aud"}, ExpiresAt: 1001}, nil},
+		{Claims{Issuer: "iss", Audience: Audience{"aud"}, ExpiresAt: 995}, nil},
+		{Claims{Issuer: "iss", Audience: Audience{"aud"}, ExpiresAt: 990}, ErrExpired},
+		{Claims{Issuer: "iss", Audience: Audience{"aud"}, NotBefore: 1005}, nil},
+		{Claims{Issuer: "iss", Audience: Audience{"aud"}, NotBefore: 1011}, ErrNotYetValid},
+		{Claims{Issuer: "iss", Audience: Audience{"aud"}, IssuedAt: 1011}, ErrIssuedInFuture},
+		{Claims{Issuer: "other", Audience: Audience{"aud"}}, ErrInvalidIssuer},
+		{Claims{Issuer: "iss", Audience: Audience{"x", "y"}}, ErrInvalidAudience},
+	}
+
+	for i, tt := range tests {
+		if err := v.Validate(&tt.claims); err != tt.err {
+			t.Fatalf("%d: %v != %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	var c Claims
+	assertNoError(t, json.Unmarshal([]byte(`{"aud":"a"}`), &c))
+	if len(c.Audience) != 1 || c.Audience[0] != "a" {
+		t.Fatalf("unexpected audience %v", c.Audience)
+	}
+	assertNoError(t, json.Unmarshal([]byte(`{"aud":["a","b"]}`), &c))
+	if len(c.Audience) != 2 || c.Audience[1] != "b" {
+		t.Fatalf("unexpected audience %v", c.Audience)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	if err != nil {
+		t.Fatalf("nil != %v", err)
+	}
+}
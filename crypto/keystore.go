@@ -0,0 +1,218 @@
+package licensekey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Encrypted private keys are stored as PEM blocks of type
+// "ENCRYPTED RSA PRIVATE KEY" carrying the PKCS#1 DER encoded key sealed with
+// AES-256-GCM. The key encryption key is derived from the passphrase using
+// PBKDF2 with HMAC-SHA256. The parameters are kept in the PEM headers:
+//
+//	KDF: PBKDF2-SHA256
+//	Iterations: 600000
+//	Salt: <hex>
+//	Cipher: AES-256-GCM
+//	Nonce: <hex>
+//
+// The PEM block type is authenticated as additional data.
+const (
+	encryptedPrivateKeyType = "ENCRYPTED RSA PRIVATE KEY"
+	kdfPBKDF2SHA256         = "PBKDF2-SHA256"
+	cipherAES256GCM         = "AES-256-GCM"
+	kdfIterations           = 600000
+	kdfSaltSize             = 16
+
+	// kdfMaxIterations bounds the iteration count accepted from key files,
+	// so crafted files cannot make decryption run almost indefinitely.
+	kdfMaxIterations = 10 * kdfIterations
+)
+
+// StorePrivateKeyEncrypted stores a RSA private key as passphrase protected
+// PEM on disk. The file is written atomically with 0600 permissions and synced
+// before being renamed into place.
+func StorePrivateKeyEncrypted(privateKey *rsa.PrivateKey, path string, passphrase []byte) error {
+	data, err := EncryptPrivateKey(privateKey, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0600)
+}
+
+// LoadPrivateKeyEncrypted loads a passphrase protected RSA private key as PEM
+// from disk.
+func LoadPrivateKeyEncrypted(path string, passphrase []byte) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return PrivateKeyFromEncryptedBytes(data, passphrase)
+}
+
+// EncryptPrivateKey encodes a RSA private key as passphrase protected PEM.
+func EncryptPrivateKey(privateKey *rsa.PrivateKey, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, kdfSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2(passphrase, salt, kdfIterations, envelopeKeySize, sha256.New)
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	der := x509.MarshalPKCS1PrivateKey(privateKey)
+	block := &pem.Block{
+		Type: encryptedPrivateKeyType,
+		Headers: map[string]string{
+			"KDF":        kdfPBKDF2SHA256,
+			"Iterations": strconv.Itoa(kdfIterations),
+			"Salt":       hex.EncodeToString(salt),
+			"Cipher":     cipherAES256GCM,
+			"Nonce":      hex.EncodeToString(nonce),
+		},
+		Bytes: aead.Seal(nil, nonce, der, []byte(encryptedPrivateKeyType)),
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// PrivateKeyFromEncryptedBytes parses a passphrase protected RSA private key as
+// PEM from bytes.
+func PrivateKeyFromEncryptedBytes(data []byte, passphrase []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != encryptedPrivateKeyType {
+		return nil, fmt.Errorf("no valid PEM data found")
+	}
+	if kdf := block.Headers["KDF"]; kdf != kdfPBKDF2SHA256 {
+		return nil, fmt.Errorf("unsupported key derivation function: %q", kdf)
+	}
+	if c := block.Headers["Cipher"]; c != cipherAES256GCM {
+		return nil, fmt.Errorf("unsupported cipher: %q", c)
+	}
+	iter, err := strconv.Atoi(block.Headers["Iterations"])
+	if err != nil || iter < 1 || iter > kdfMaxIterations {
+		return nil, fmt.Errorf("invalid iteration count: %q", block.Headers["Iterations"])
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("invalid salt: %q", block.Headers["Salt"])
+	}
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %q", block.Headers["Nonce"])
+	}
+
+	key := pbkdf2(passphrase, salt, iter, envelopeKeySize, sha256.New)
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce: %q", block.Headers["Nonce"])
+	}
+	der, err := aead.Open(nil, nonce, block.Bytes, []byte(block.Type))
+	if err != nil {
+		return nil, fmt.Errorf("private key can't be decrypted: wrong passphrase or corrupted data")
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("private key can't be decoded: %s", err)
+	}
+
+	return privateKey, nil
+}
+
+// pbkdf2 derives a key from a password as specified in RFC 8018, section 5.2.
+func pbkdf2(password, salt []byte, iter, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+
+	return dk[:keyLen]
+}
+
+// writeFileAtomic writes data to a temporary file next to filename, syncs it
+// and renames it into place, so readers never observe a partially written
+// file.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpname := f.Name()
+
+	err = f.Chmod(perm)
+	if err == nil {
+		var n int
+		n, err = f.Write(data)
+		if err == nil && n < len(data) {
+			err = io.ErrShortWrite
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmpname, filename)
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
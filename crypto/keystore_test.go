@@ -0,0 +1,54 @@
+package licensekey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreLoadPrivateKeyEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	assertNoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key.pem")
+
+	err = StorePrivateKeyEncrypted(privateKey, path, []byte("secret"))
+	assertNoError(t, err)
+
+	info, err := os.Stat(path)
+	assertNoError(t, err)
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("0600 != %o", info.Mode().Perm())
+	}
+
+	key, err := LoadPrivateKeyEncrypted(path, []byte("secret"))
+	assertNoError(t, err)
+	assertEqualPrivateKey(t, privateKey, key)
+
+	if _, err := LoadPrivateKeyEncrypted(path, []byte("wrong")); err == nil {
+		t.Fatal("expected error loading key with wrong passphrase")
+	}
+}
+
+func TestPrivateKeyFromEncryptedBytesIterations(t *testing.T) {
+	data, err := EncryptPrivateKey(privateKey, []byte("secret"))
+	assertNoError(t, err)
+
+	for _, iter := range []string{"0", "-1", "6000001", "99999999999"} {
+		crafted := strings.Replace(string(data), "Iterations: 600000", "Iterations: "+iter, 1)
+		if _, err := PrivateKeyFromEncryptedBytes([]byte(crafted), []byte("secret")); err == nil {
+			t.Fatalf("%s: expected error", iter)
+		}
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vector from RFC 7914, section 11.
+	dk := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64, sha256.New)
+	assertEqualString(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"+
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(dk))
+}
@@ -0,0 +1,184 @@
+package licensekey
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Envelope layout produced by Seal and SealMulti. All integers are big endian.
+//
+//	magic       4 bytes  "RSAE"
+//	version     1 byte   envelopeVersion
+//	recipients  2 bytes  number of recipient entries
+//	recipient   repeated:
+//	    key id      32 bytes  SHA-256 of the recipient's PKIX public key
+//	    key length   2 bytes  length of the wrapped key
+//	    wrapped key  n bytes  RSA-OAEP (SHA-256) encrypted AES-256 key
+//	nonce      12 bytes  AES-GCM nonce
+//	ciphertext  n bytes  AES-GCM sealed plaintext
+//
+// Everything preceding the ciphertext is authenticated as additional data.
+const (
+	envelopeMagic   = "RSAE"
+	envelopeVersion = 1
+	envelopeKeySize = 32
+	keyIDSize       = sha256.Size
+)
+
+// envelopeLabel is the OAEP label binding wrapped keys to the envelope format.
+var envelopeLabel = []byte("djui/pkg/crypto envelope v1")
+
+// Seal encrypts plaintext for the owner of a given RSA public key. A random
+// AES-256 key encrypts the plaintext using AES-GCM and is itself wrapped using
+// RSA-OAEP. The result is a self-describing envelope which can be decrypted
+// with Open.
+func Seal(publicKey *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	return SealMulti([]*rsa.PublicKey{publicKey}, plaintext)
+}
+
+// SealMulti behaves just like Seal except that the envelope can be opened by
+// the owner of any of the given RSA public keys.
+func SealMulti(publicKeys []*rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	if len(publicKeys) == 0 {
+		return nil, fmt.Errorf("no recipients given")
+	}
+	if len(publicKeys) > 0xffff {
+		return nil, fmt.Errorf("too many recipients: %d", len(publicKeys))
+	}
+
+	key := make([]byte, envelopeKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(envelopeMagic)
+	buf.WriteByte(envelopeVersion)
+	binary.Write(&buf, binary.BigEndian, uint16(len(publicKeys)))
+
+	for _, publicKey := range publicKeys {
+		id, err := KeyID(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, envelopeLabel)
+		if err != nil {
+			return nil, fmt.Errorf("key can't be wrapped: %s", err)
+		}
+		buf.Write(id)
+		binary.Write(&buf, binary.BigEndian, uint16(len(wrapped)))
+		buf.Write(wrapped)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	buf.Write(nonce)
+
+	header := buf.Bytes()
+	out := make([]byte, len(header), len(header)+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// Open decrypts an envelope created by Seal or SealMulti using a given RSA
+// private key.
+func Open(privateKey *rsa.PrivateKey, envelope []byte) ([]byte, error) {
+	id, err := KeyID(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(envelope)
+	magic := make([]byte, len(envelopeMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != envelopeMagic {
+		return nil, fmt.Errorf("no valid envelope found")
+	}
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("envelope is truncated")
+	}
+	if version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version: %d", version)
+	}
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, fmt.Errorf("envelope is truncated")
+	}
+
+	var wrapped []byte
+	for i := 0; i < int(n); i++ {
+		recipient := make([]byte, keyIDSize)
+		if _, err := io.ReadFull(r, recipient); err != nil {
+			return nil, fmt.Errorf("envelope is truncated")
+		}
+		var size uint16
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, fmt.Errorf("envelope is truncated")
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("envelope is truncated")
+		}
+		if bytes.Equal(recipient, id) {
+			wrapped = data
+		}
+	}
+	if wrapped == nil {
+		return nil, fmt.Errorf("envelope is not addressed to the given key")
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, wrapped, envelopeLabel)
+	if err != nil {
+		return nil, fmt.Errorf("key can't be unwrapped: %s", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, fmt.Errorf("envelope is truncated")
+	}
+
+	header := envelope[:len(envelope)-r.Len()]
+	plaintext, err := aead.Open(nil, nonce, envelope[len(header):], header)
+	if err != nil {
+		return nil, fmt.Errorf("envelope can't be decrypted: %s", err)
+	}
+
+	return plaintext, nil
+}
+
+// KeyID returns the SHA-256 fingerprint of a RSA public key's PKIX encoding.
+func KeyID(publicKey *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	id := sha256.Sum256(der)
+	return id[:], nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,52 @@
+package licensekey
+
+import (
+	"crypto/rsa"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	envelope, err := Seal(publicKey, tMessage)
+	assertNoError(t, err)
+	plaintext, err := Open(privateKey, envelope)
+	assertNoError(t, err)
+	assertEqualString(t, string(tMessage), string(plaintext))
+}
+
+func TestSealMulti(t *testing.T) {
+	otherKey, err := GenerateKeys(2048)
+	assertNoError(t, err)
+
+	envelope, err := SealMulti([]*rsa.PublicKey{publicKey, &otherKey.PublicKey}, tMessage)
+	assertNoError(t, err)
+
+	for _, key := range []*rsa.PrivateKey{privateKey, otherKey} {
+		plaintext, err := Open(key, envelope)
+		assertNoError(t, err)
+		assertEqualString(t, string(tMessage), string(plaintext))
+	}
+}
+
+func TestOpenNotAddressed(t *testing.T) {
+	otherKey, err := GenerateKeys(2048)
+	assertNoError(t, err)
+
+	envelope, err := Seal(publicKey, tMessage)
+	assertNoError(t, err)
+	if _, err := Open(otherKey, envelope); err == nil {
+		t.Fatal("expected error opening envelope with wrong key")
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	envelope, err := Seal(publicKey, tMessage)
+	assertNoError(t, err)
+
+	for _, i := range []int{5, len(envelope) - 1} {
+		tampered := append([]byte(nil), envelope...)
+		tampered[i] ^= 0xff
+		if _, err := Open(privateKey, tampered); err == nil {
+			t.Fatalf("expected error opening envelope tampered at %d", i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package licensekey
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const signatureType = "RSA SIGNATURE"
+
+// Signer hashes data written to it incrementally and RSA PKCS#1 v1.5 signs
+// the digest. Signatures are identical to those created by Sign over the
+// concatenated data.
+type Signer struct {
+	hash.Hash
+	key *rsa.PrivateKey
+}
+
+// NewSigner returns a Signer for a given private key.
+func NewSigner(key *rsa.PrivateKey) *Signer {
+	return &Signer{sha256.New(), key}
+}
+
+// Sign returns the signature of the data written so far.
+func (s *Signer) Sign() ([]byte, error) {
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, s.Sum(nil))
+}
+
+// Verifier hashes data written to it incrementally and RSA PKCS#1 v1.5
+// verifies a signature against the digest.
+type Verifier struct {
+	hash.Hash
+	key *rsa.PublicKey
+	sig []byte
+}
+
+// NewVerifier returns a Verifier for a given public key and signature.
+func NewVerifier(key *rsa.PublicKey, sig []byte) *Verifier {
+	return &Verifier{sha256.New(), key, sig}
+}
+
+// Verify verifies the signature against the data written so far.
+func (v *Verifier) Verify() error {
+	return rsa.VerifyPKCS1v15(v.key, crypto.SHA256, v.Sum(nil), v.sig)
+}
+
+// SignFile RSA PKCS#1 v1.5 signs the content of a file, reading it in a
+// streaming fashion.
+func SignFile(key *rsa.PrivateKey, path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := NewSigner(key)
+	if _, err := io.Copy(s, f); err != nil {
+		return nil, err
+	}
+
+	return s.Sign()
+}
+
+// VerifyFile RSA PKCS#1 v1.5 verifies a signature against the content of a
+// file, reading it in a streaming fashion.
+func VerifyFile(key *rsa.PublicKey, path string, sig []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	v := NewVerifier(key, sig)
+	if _, err := io.Copy(v, f); err != nil {
+		return err
+	}
+
+	return v.Verify()
+}
+
+// StoreSignature stores a detached signature as PEM on disk. By convention
+// the signature of a file is stored next to it with a ".sig" suffix.
+func StoreSignature(sig []byte, path string) error {
+	return ioutil.WriteFile(path, SignatureToBytes(sig), 0644)
+}
+
+// LoadSignature loads a detached signature as PEM from disk.
+func LoadSignature(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return SignatureFromBytes(data)
+}
+
+// SignatureToBytes encodes a detached signature as PEM. The headers record
+// the signature scheme, so the file is self-describing.
+func SignatureToBytes(sig []byte) []byte {
+	block := &pem.Block{
+		Type: signatureType,
+		Headers: map[string]string{
+			"Algorithm": "RSASSA-PKCS1-v1_5",
+			"Hash":      "SHA-256",
+		},
+		Bytes: sig,
+	}
+	return pem.EncodeToMemory(block)
+}
+
+// SignatureFromBytes parses a detached signature as PEM from bytes.
+func SignatureFromBytes(data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != signatureType {
+		return nil, fmt.Errorf("no valid PEM data found")
+	}
+	if alg := block.Headers["Algorithm"]; alg != "RSASSA-PKCS1-v1_5" {
+		return nil, fmt.Errorf("unsupported signature algorithm: %q", alg)
+	}
+	if h := block.Headers["Hash"]; h != "SHA-256" {
+		return nil, fmt.Errorf("unsupported hash: %q", h)
+	}
+
+	return block.Bytes, nil
+}
@@ -0,0 +1,42 @@
+package licensekey
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner(privateKey)
+	io.WriteString(s, "mes")
+	io.WriteString(s, "sage")
+	signature, err := s.Sign()
+	assertNoError(t, err)
+	assertEqualString(t, string(tSignature), string(signature))
+}
+
+func TestVerifier(t *testing.T) {
+	v := NewVerifier(publicKey, tSignature)
+	io.WriteString(v, "mes")
+	io.WriteString(v, "sage")
+	assertNoError(t, v.Verify())
+}
+
+func TestSignVerifyFile(t *testing.T) {
+	tmpfile, _ := ioutil.TempFile("", "test")
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Write(tMessage)
+	tmpfile.Close()
+
+	signature, err := SignFile(privateKey, tmpfile.Name())
+	assertNoError(t, err)
+	assertEqualString(t, string(tSignature), string(signature))
+
+	sigfilename := tmpfile.Name() + ".sig"
+	defer os.Remove(sigfilename)
+	assertNoError(t, StoreSignature(signature, sigfilename))
+	signature, err = LoadSignature(sigfilename)
+	assertNoError(t, err)
+	assertNoError(t, VerifyFile(publicKey, tmpfile.Name(), signature))
+}
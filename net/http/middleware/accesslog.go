@@ -0,0 +1,265 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdlog "log"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djui/pkg/log"
+	timeutil "github.com/djui/pkg/time"
+)
+
+// RequestIDHeader is the header carrying request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// LogFormat defines the format of access log lines.
+type LogFormat int
+
+const (
+	// CommonLogFormat logs in the NCSA Common Log Format.
+	CommonLogFormat LogFormat = iota
+	// CombinedLogFormat logs in the NCSA Combined Log Format, which adds the
+	// referer and user agent to the Common Log Format.
+	CombinedLogFormat
+	// JSONLogFormat logs a JSON object per request.
+	JSONLogFormat
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID assigned by AccessLogger, or an
+// empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// AccessLogger logs a line per request, including the response status, the
+// number of body bytes written and the duration. Each request is assigned a
+// request ID, which is taken from the X-Request-ID request header if valid or
+// generated otherwise. It is set as X-Request-ID header on the request passed
+// to the handler and on the response, and can be retrieved via
+// RequestIDFromContext.
+type AccessLogger struct {
+	// Logger receives the log lines. If nil, they are written to standard
+	// error.
+	Logger log.Logger
+	Format LogFormat
+	// SampleRate is the fraction of requests to log, in [0, 1]. Responses
+	// with status codes of 500 and above are always logged. If zero, all
+	// requests are logged.
+	SampleRate float64
+	// Skip lists URL path prefixes of requests not to log, e.g. "/healthz".
+	Skip []string
+	// Clock measures durations. If nil, RealClock is used.
+	Clock timeutil.Clock
+}
+
+// AccessLog returns a wrapper function (often known as middleware) which can
+// be used to wrap an HTTP handler to log all requests in the Combined Log
+// Format.
+func AccessLog(logger log.Logger) func(http.Handler) http.Handler {
+	a := &AccessLogger{Logger: logger, Format: CombinedLogFormat}
+	return a.Handler
+}
+
+// Handler wraps an HTTP handler to log its requests.
+func (a *AccessLogger) Handler(h http.Handler) http.Handler {
+	logger := a.Logger
+	if logger == nil {
+		logger = stdlog.New(os.Stderr, "", stdlog.LstdFlags)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock := a.Clock
+		if clock == nil {
+			clock = timeutil.RealClock{}
+		}
+		start := clock.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		lw := &statusResponseWriter{responseWriter: responseWriter{w}}
+		defer func() {
+			if lw.status == 0 {
+				lw.status = http.StatusOK
+			}
+			if a.skip(r, lw.status) {
+				return
+			}
+			logger.Print(a.format(r, lw, start, clock.Now().Sub(start)))
+		}()
+		h.ServeHTTP(lw, r)
+	})
+}
+
+// skip reports whether a request should not be logged.
+func (a *AccessLogger) skip(r *http.Request, status int) bool {
+	for _, prefix := range a.Skip {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	if status >= 500 || a.SampleRate <= 0 || a.SampleRate >= 1 {
+		return false
+	}
+	return mathrand.Float64() >= a.SampleRate
+}
+
+func (a *AccessLogger) format(r *http.Request, w *statusResponseWriter, start time.Time, d time.Duration) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if a.Format == JSONLogFormat {
+		b, _ := json.Marshal(struct {
+			Time       string  `json:"time"`
+			RequestID  string  `json:"request_id"`
+			RemoteAddr string  `json:"remote_addr"`
+			Method     string  `json:"method"`
+			URI        string  `json:"uri"`
+			Proto      string  `json:"proto"`
+			Status     int     `json:"status"`
+			Bytes      int64   `json:"bytes"`
+			Duration   float64 `json:"duration_ms"`
+			Referer    string  `json:"referer,omitempty"`
+			UserAgent  string  `json:"user_agent,omitempty"`
+		}{
+			Time:       start.Format(time.RFC3339Nano),
+			RequestID:  RequestIDFromContext(r.Context()),
+			RemoteAddr: host,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Status:     w.status,
+			Bytes:      w.bytes,
+			Duration:   float64(d) / float64(time.Millisecond),
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		})
+		return string(b)
+	}
+
+	user := "-"
+	if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	} else if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	size := "-"
+	if w.bytes > 0 {
+		size = strconv.FormatInt(w.bytes, 10)
+	}
+
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		host, escapeLogValue(user), start.Format("02/Jan/2006:15:04:05 -0700"),
+		escapeLogValue(r.Method), escapeLogValue(r.RequestURI), escapeLogValue(r.Proto),
+		w.status, size)
+	if a.Format == CombinedLogFormat {
+		line += fmt.Sprintf(` "%s" "%s"`, escapeLogValue(r.Referer()), escapeLogValue(r.UserAgent()))
+	}
+	return line
+}
+
+// escapeLogValue escapes quotes, backslashes and control characters, so
+// clients cannot forge log lines.
+func escapeLogValue(s string) string {
+	q := strconv.QuoteToASCII(s)
+	return q[1 : len(q)-1]
+}
+
+// validRequestID reports whether a client provided request ID is safe to use.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// statusResponseWriter captures the status code and number of body bytes
+// of a response.
+type statusResponseWriter struct {
+	responseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying http.ResponseWriter if it is an http.Flusher.
+func (w *statusResponseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.responseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does.
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.responseWriter.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// ReadFrom implements the io.ReaderFrom interface, passing on to the
+// underlying http.ResponseWriter if it is an io.ReaderFrom.
+func (w *statusResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if _, ok := w.ResponseWriter.(io.ReaderFrom); !ok {
+		// Write counts the bytes copied.
+		return io.Copy(writerOnly{w}, r)
+	}
+	n, err := w.readFrom(w, r)
+	w.bytes += n
+	return n, err
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	timeutil "github.com/djui/pkg/time"
+)
+
+func TestAccessLogger(t *testing.T) {
+	var buf bytes.Buffer
+	clock := timeutil.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	a := &AccessLogger{Logger: stdlog.New(&buf, "", 0), Clock: clock, Skip: []string{"/healthz"}}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RequestIDFromContext(r.Context()) != r.Header.Get(RequestIDHeader) {
+			t.Fatal("request ID not propagated")
+		}
+		clock.Advance(1500 * time.Microsecond)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "hello")
+	}))
+
+	tests := []struct {
+		format   LogFormat
+		expected string
+	}{
+		{CommonLogFormat, `192.0.2.1 - alice [02/Jan/2021:03:04:05 +0000] "GET /path?q=\"x\" HTTP/1.1" 201 5`},
+		{CombinedLogFormat, `192.0.2.1 - alice [02/Jan/2021:03:04:05 +0000] "GET /path?q=\"x\" HTTP/1.1" 201 5 "http://example.com/" "test\n"`},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		a.Format = tt.format
+		r := httptest.NewRequest("GET", `/path?q="x"`, nil)
+		r.SetBasicAuth("alice", "secret")
+		r.Header.Set("Referer", "http://example.com/")
+		r.Header.Set("User-Agent", "test\n")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if actual := strings.TrimSuffix(buf.String(), "\n"); actual != tt.expected {
+			t.Fatalf("%q != %q", tt.expected, actual)
+		}
+		if len(w.Header().Get(RequestIDHeader)) != 32 {
+			t.Fatalf("unexpected request ID %q", w.Header().Get(RequestIDHeader))
+		}
+	}
+
+	buf.Reset()
+	a.Format = JSONLogFormat
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["request_id"] != "abc-123" || entry["status"] != 201.0 || entry["bytes"] != 5.0 || entry["duration_ms"] != 1.5 {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if w.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Fatal("request ID not propagated to response")
+	}
+
+	buf.Reset()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected log %q", buf.String())
+	}
+}
+
+func TestAccessLoggerDefaultLogger(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	h := AccessLog(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	os.Stderr = stderr
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	w.Close()
+	line, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(line), `"GET / HTTP/1.1" 200`) {
+		t.Fatalf("unexpected log %q", line)
+	}
+}
+
+func TestAccessLoggerSampling(t *testing.T) {
+	var buf bytes.Buffer
+	a := &AccessLogger{Logger: stdlog.New(&buf, "", 0), Format: JSONLogFormat, SampleRate: 0.000001}
+	status := http.StatusOK
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	for i := 0; i < 100; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if n := strings.Count(buf.String(), "\n"); n > 1 {
+		t.Fatalf("%d requests logged", n)
+	}
+
+	buf.Reset()
+	status = http.StatusInternalServerError
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if buf.Len() == 0 {
+		t.Fatal("expected server error to be logged")
+	}
+}
@@ -1,13 +1,19 @@
 package middleware
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CacheControl provides a HTTP middleware to emits Cache-Control headers for
-// selected URL prefixes.
+// selected URL prefixes. See NewCacheControl for a variant composable with
+// Chain.
 func CacheControl(next http.Handler, age int, prefixes ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cacheable := false
@@ -25,3 +31,270 @@ func CacheControl(next http.Handler, age int, prefixes ...string) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// NewCacheControl returns a wrapper function (often known as middleware)
+// which can be used to wrap an HTTP handler to emit Cache-Control headers for
+// selected URL prefixes, as CacheControl does.
+func NewCacheControl(age int, prefixes ...string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return CacheControl(h, age, prefixes...)
+	}
+}
+
+// CacheDirectives describes the directives of a Cache-Control response
+// header. Durations are rounded down to seconds; zero durations are omitted,
+// so use NoCache for responses which must be revalidated on every use.
+//
+// See: https://tools.ietf.org/html/rfc7234#section-5.2.2
+type CacheDirectives struct {
+	Public          bool
+	Private         bool
+	NoCache         bool
+	NoStore         bool
+	MustRevalidate  bool
+	ProxyRevalidate bool
+	NoTransform     bool
+	// Immutable tells clients not to revalidate while fresh.
+	//
+	// See: https://tools.ietf.org/html/rfc8246
+	Immutable bool
+
+	MaxAge  time.Duration
+	SMaxAge time.Duration
+	// StaleWhileRevalidate and StaleIfError allow serving stale responses
+	// for a time.
+	//
+	// See: https://tools.ietf.org/html/rfc5861
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// String returns the value of the Cache-Control header.
+func (d CacheDirectives) String() string {
+	var parts []string
+	flag := func(set bool, name string) {
+		if set {
+			parts = append(parts, name)
+		}
+	}
+	delta := func(v time.Duration, name string) {
+		if v > 0 {
+			parts = append(parts, name+"="+strconv.FormatInt(int64(v/time.Second), 10))
+		}
+	}
+
+	flag(d.Public, "public")
+	flag(d.Private, "private")
+	flag(d.NoCache, "no-cache")
+	flag(d.NoStore, "no-store")
+	delta(d.MaxAge, "max-age")
+	delta(d.SMaxAge, "s-maxage")
+	delta(d.StaleWhileRevalidate, "stale-while-revalidate")
+	delta(d.StaleIfError, "stale-if-error")
+	flag(d.MustRevalidate, "must-revalidate")
+	flag(d.ProxyRevalidate, "proxy-revalidate")
+	flag(d.NoTransform, "no-transform")
+	flag(d.Immutable, "immutable")
+	return strings.Join(parts, ", ")
+}
+
+// defaultCacheableStatuses lists the status codes which are cacheable by
+// default.
+//
+// See: https://tools.ietf.org/html/rfc7231#section-6.1
+var defaultCacheableStatuses = []int{200, 203, 204, 206, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// CacheRule matches responses to emit Cache-Control directives for.
+type CacheRule struct {
+	// Path is a glob matched against the URL path. Besides the syntax of
+	// path.Match, "**" matches any number of path segments, e.g.
+	// "/static/**/*.css". An empty Path matches any path.
+	Path string
+	// Methods lists the request methods to match. If empty, GET and HEAD are
+	// matched.
+	Methods []string
+	// Statuses lists the response status codes to match. If empty, the status
+	// codes defined as cacheable by default in RFC 7231 are matched.
+	Statuses []int
+	// ContentTypes lists the media types to match. Entries may use a wildcard
+	// subtype, e.g. "image/*". If empty, any media type is matched. Handlers
+	// must set the Content-Type header before writing the response for it to
+	// be matched.
+	ContentTypes []string
+	// AllowSetCookie allows the directives to apply to responses setting
+	// cookies, which are otherwise never stored.
+	AllowSetCookie bool
+
+	Directives CacheDirectives
+}
+
+func (rule *CacheRule) match(r *http.Request, status int, contentType string) bool {
+	if rule.Path != "" && !matchPathGlob(rule.Path, r.URL.Path) {
+		return false
+	}
+
+	methods := rule.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	found := false
+	for _, m := range methods {
+		if strings.EqualFold(m, r.Method) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	statuses := rule.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultCacheableStatuses
+	}
+	found = false
+	for _, s := range statuses {
+		if s == status {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	if len(rule.ContentTypes) > 0 {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+		return matchMediaType(rule.ContentTypes, mediaType)
+	}
+	return true
+}
+
+// matchPathGlob reports whether a URL path matches a glob pattern, in which
+// "**" matches any number of path segments.
+func matchPathGlob(pattern, p string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(segments); i >= 0; i-- {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); !ok || err != nil {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// CachePolicy emits Cache-Control headers according to the first matching
+// rule. Cache-Control headers set by handlers are kept.
+//
+// Responses setting cookies are marked "private, no-store", unless the
+// matching rule allows them, so user specific responses never end up in shared
+// caches.
+type CachePolicy struct {
+	Rules []CacheRule
+	// Default holds the directives for responses matched by no rule. If
+	// empty, no Cache-Control header is emitted.
+	Default CacheDirectives
+}
+
+// Handler wraps an HTTP handler to emit Cache-Control headers for its
+// responses.
+func (p *CachePolicy) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &cachePolicyResponseWriter{responseWriter: responseWriter{w}, p: p, r: r}
+		h.ServeHTTP(cw, r)
+		if !cw.wroteHeader && !cw.hijacked {
+			// Handlers writing nothing implicitly respond with 200 OK.
+			cw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// directives returns the directives for a response.
+func (p *CachePolicy) directives(r *http.Request, status int, header http.Header) CacheDirectives {
+	_, setsCookie := header["Set-Cookie"]
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.match(r, status, header.Get("Content-Type")) {
+			continue
+		}
+		if setsCookie && !rule.AllowSetCookie {
+			break
+		}
+		return rule.Directives
+	}
+	if setsCookie {
+		return CacheDirectives{Private: true, NoStore: true}
+	}
+	return p.Default
+}
+
+// cachePolicyResponseWriter emits the Cache-Control header once the status
+// code is known.
+type cachePolicyResponseWriter struct {
+	responseWriter
+	p           *CachePolicy
+	r           *http.Request
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *cachePolicyResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.wroteHeader = true
+		h := w.Header()
+		if _, ok := h["Cache-Control"]; !ok {
+			if v := w.p.directives(w.r, code, h).String(); v != "" {
+				h.Set("Cache-Control", v)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cachePolicyResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying http.ResponseWriter if it is an http.Flusher.
+func (w *cachePolicyResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.responseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does.
+func (w *cachePolicyResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.responseWriter.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// ReadFrom implements the io.ReaderFrom interface, passing on to the
+// underlying http.ResponseWriter if it is an io.ReaderFrom.
+func (w *cachePolicyResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.readFrom(w, r)
+}
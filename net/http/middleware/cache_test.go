@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheDirectives(t *testing.T) {
+	d := CacheDirectives{
+		Public:               true,
+		MaxAge:               time.Hour,
+		SMaxAge:              90 * time.Second,
+		StaleWhileRevalidate: time.Minute,
+		Immutable:            true,
+	}
+	expected := "public, max-age=3600, s-maxage=90, stale-while-revalidate=60, immutable"
+	if actual := d.String(); actual != expected {
+		t.Fatalf("%q != %q", expected, actual)
+	}
+}
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		expected      bool
+	}{
+		{"/static/*", "/static/app.js", true},
+		{"/static/*", "/static/js/app.js", false},
+		{"/static/**", "/static/js/app.js", true},
+		{"/static/**/*.css", "/static/style.css", true},
+		{"/static/**/*.css", "/static/a/b/style.css", true},
+		{"/static/**/*.css", "/static/a/b/app.js", false},
+		{"/api/v?/users", "/api/v1/users", true},
+	}
+
+	for _, tt := range tests {
+		if actual := matchPathGlob(tt.pattern, tt.path); actual != tt.expected {
+			t.Fatalf("%s %s: expected %t", tt.pattern, tt.path, tt.expected)
+		}
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	p := &CachePolicy{
+		Rules: []CacheRule{
+			{Path: "/static/**", ContentTypes: []string{"image/*"},
+				Directives: CacheDirectives{Public: true, MaxAge: 365 * 24 * time.Hour, Immutable: true}},
+			{Path: "/static/**", Directives: CacheDirectives{Public: true, MaxAge: time.Hour}},
+			{Path: "/api/**", Methods: []string{"GET", "POST"}, Directives: CacheDirectives{Private: true, NoCache: true}},
+		},
+		Default: CacheDirectives{NoStore: true},
+	}
+
+	tests := []struct {
+		method, path, contentType string
+		status                    int
+		cookie                    bool
+		expected                  string
+	}{
+		{"GET", "/static/logo.png", "image/png", 200, false, "public, max-age=31536000, immutable"},
+		{"GET", "/static/app.js", "text/javascript", 200, false, "public, max-age=3600"},
+		{"GET", "/static/app.js", "text/javascript", 500, false, "no-store"},
+		{"GET", "/static/app.js", "text/javascript", 200, true, "private, no-store"},
+		{"POST", "/api/users", "application/json", 200, false, "private, no-cache"},
+		{"PUT", "/api/users", "application/json", 200, false, "no-store"},
+		{"GET", "/", "text/html", 200, false, "no-store"},
+	}
+
+	for _, tt := range tests {
+		h := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			if tt.cookie {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+			}
+			w.WriteHeader(tt.status)
+		}))
+
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if actual := w.Header().Get("Cache-Control"); actual != tt.expected {
+			t.Fatalf("%s %s: %q != %q", tt.method, tt.path, tt.expected, actual)
+		}
+	}
+}
+
+func TestCachePolicyInterfaces(t *testing.T) {
+	p := &CachePolicy{Default: CacheDirectives{NoStore: true}}
+
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	p.Handler(hijackHandler(t)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.hijacked || w.lateHeaders != 0 {
+		t.Fatalf("unexpected hijack %t after %d headers", w.hijacked, w.lateHeaders)
+	}
+
+	h := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := w.(http.Pusher).Push("/style.css", nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	pw := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(pw, httptest.NewRequest("GET", "/", nil))
+	if pw.pushed != "/style.css" || pw.Body.String() != "hello" || pw.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected response %q %q", pw.pushed, pw.Body)
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps an HTTP handler. Functions such as Compress and methods
+// such as (*CORS).Handler are Middleware.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middleware. The first middleware is the outermost, i.e. it
+// sees requests first and responses last. Chains are immutable, so a chain can
+// be shared as the base of several per-route chains.
+type Chain struct {
+	middleware []Middleware
+}
+
+// NewChain returns a chain of middleware.
+func NewChain(middleware ...Middleware) Chain {
+	return Chain{middleware: append([]Middleware(nil), middleware...)}
+}
+
+// Append returns a new chain with middleware added to the end.
+func (c Chain) Append(middleware ...Middleware) Chain {
+	m := make([]Middleware, 0, len(c.middleware)+len(middleware))
+	m = append(m, c.middleware...)
+	return Chain{middleware: append(m, middleware...)}
+}
+
+// Extend returns a new chain with the middleware of another chain added to
+// the end.
+func (c Chain) Extend(other Chain) Chain {
+	return c.Append(other.middleware...)
+}
+
+// Then wraps an HTTP handler with the chain's middleware. If h is nil,
+// http.DefaultServeMux is used.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	return h
+}
+
+// ThenFunc wraps an HTTP handler function with the chain's middleware.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	if fn == nil {
+		return c.Then(nil)
+	}
+	return c.Then(fn)
+}
+
+// Matcher reports whether middleware applies to a request.
+type Matcher func(r *http.Request) bool
+
+// PathPrefix returns a Matcher matching requests whose URL path has one of
+// the prefixes.
+func PathPrefix(prefixes ...string) Matcher {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Method returns a Matcher matching requests with one of the methods.
+func Method(methods ...string) Matcher {
+	return func(r *http.Request) bool {
+		for _, m := range methods {
+			if strings.EqualFold(m, r.Method) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// When returns middleware applying the given middleware to requests matched
+// by match only. Other requests are passed to the wrapped handler directly.
+func When(match Matcher, middleware ...Middleware) Middleware {
+	c := NewChain(middleware...)
+	return func(h http.Handler) http.Handler {
+		wrapped := c.Then(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if match(r) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func tag(name string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	base := NewChain(tag("a"), tag("b"))
+	api := base.Append(tag("c"))
+	static := base.Extend(NewChain(tag("d"), NewCacheControl(60, "/static/")))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "!")
+	})
+
+	tests := []struct {
+		c        Chain
+		path     string
+		expected string
+		cache    string
+	}{
+		{base, "/", "ab!", ""},
+		{api, "/", "abc!", ""},
+		{static, "/static/app.js", "abd!", "public, max-age=60"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.c.ThenFunc(handler).ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Body.String() != tt.expected || w.Header().Get("Cache-Control") != tt.cache {
+			t.Fatalf("%s: unexpected response %q %q", tt.expected, w.Body, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestWhen(t *testing.T) {
+	h := NewChain(
+		When(PathPrefix("/api/"), tag("api")),
+		When(Method("POST", "PUT"), tag("write"), tag("!")),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/", ""},
+		{"GET", "/api/users", "api"},
+		{"post", "/", "write!"},
+		{"PUT", "/api/users", "apiwrite!"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Body.String() != tt.expected {
+			t.Fatalf("%s %s: %q != %q", tt.method, tt.path, tt.expected, w.Body)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResetWriter is a compressing writer which can be reused for another
+// destination, such as *gzip.Writer, *zlib.Writer, *zstd.Encoder or
+// *brotli.Writer.
+type ResetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// Encoder compresses response bodies with a content-coding.
+type Encoder interface {
+	// Encoding returns the content-coding as used in the Accept-Encoding and
+	// Content-Encoding headers, e.g. "gzip" or "br".
+	Encoding() string
+	// NewWriter returns a writer compressing to w. Closing it writes any
+	// remaining data, but does not close w.
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+// zlibWriterPools stores a sync.Pool for each compression level for re-use
+// of zlib.Writers, indexed like gzipWriterPools.
+var zlibWriterPools [zlib.BestCompression - zlib.BestSpeed + 2]*sync.Pool
+
+func init() {
+	for i := zlib.BestSpeed; i <= zlib.BestCompression; i++ {
+		addZlibLevelPool(i)
+	}
+	addZlibLevelPool(zlib.DefaultCompression)
+}
+
+func addZlibLevelPool(level int) {
+	zlibWriterPools[poolIndex(level)] = &sync.Pool{
+		New: func() interface{} {
+			// NewWriterLevel only returns error on a bad level, which
+			// addZlibLevelPool is never called with.
+			w, _ := zlib.NewWriterLevel(nil, level)
+			return w
+		},
+	}
+}
+
+// NewGzipEncoder returns an Encoder for the "gzip" content-coding compressing
+// at a given level. An error is returned only if an invalid gzip compression
+// level is given.
+func NewGzipEncoder(level int) (Encoder, error) {
+	if level != gzip.DefaultCompression && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+		return nil, fmt.Errorf("invalid compression level requested: %d", level)
+	}
+	return pooledEncoder{"gzip", gzipWriterPools[poolIndex(level)]}, nil
+}
+
+// NewDeflateEncoder returns an Encoder for the "deflate" content-coding
+// compressing at a given level. As defined by RFC 7230, the deflate stream is
+// wrapped in the zlib format. An error is returned only if an invalid zlib
+// compression level is given.
+func NewDeflateEncoder(level int) (Encoder, error) {
+	if level != zlib.DefaultCompression && (level < zlib.BestSpeed || level > zlib.BestCompression) {
+		return nil, fmt.Errorf("invalid compression level requested: %d", level)
+	}
+	return pooledEncoder{"deflate", zlibWriterPools[poolIndex(level)]}, nil
+}
+
+// NewEncoder returns an Encoder for a given content-coding, which pools the
+// writers returned by newWriter. This allows to plug in encodings not
+// supported by the standard library, e.g. for brotli:
+//
+//	middleware.NewEncoder("br", func() middleware.ResetWriter {
+//		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+//	})
+func NewEncoder(encoding string, newWriter func() ResetWriter) Encoder {
+	return pooledEncoder{
+		encoding: strings.ToLower(encoding),
+		pool:     &sync.Pool{New: func() interface{} { return newWriter() }},
+	}
+}
+
+type pooledEncoder struct {
+	encoding string
+	pool     *sync.Pool
+}
+
+func (e pooledEncoder) Encoding() string { return e.encoding }
+
+func (e pooledEncoder) NewWriter(w io.Writer) io.WriteCloser {
+	rw := e.pool.Get().(ResetWriter)
+	rw.Reset(w)
+	return &pooledWriter{rw, e.pool}
+}
+
+// pooledWriter returns its writer to the pool once closed.
+type pooledWriter struct {
+	ResetWriter
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.ResetWriter.Close()
+	// Drop the reference to the destination while pooled.
+	w.ResetWriter.Reset(nil)
+	w.pool.Put(w.ResetWriter)
+	return err
+}
+
+// Flush flushes the underlying writer if it supports flushing.
+func (w *pooledWriter) Flush() error {
+	if f, ok := w.ResetWriter.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// EncoderRegistry holds the encoders available for compressing responses in
+// order of server preference. It is safe for concurrent use.
+type EncoderRegistry struct {
+	mu       sync.RWMutex
+	encoders []Encoder
+}
+
+// NewEncoderRegistry returns a registry holding the given encoders, the most
+// preferred first.
+func NewEncoderRegistry(encoders ...Encoder) *EncoderRegistry {
+	r := &EncoderRegistry{}
+	for _, e := range encoders {
+		r.Register(e)
+	}
+	return r
+}
+
+// DefaultEncoders returns a registry preferring gzip over deflate, both at the
+// default compression level.
+func DefaultEncoders() *EncoderRegistry {
+	gz, _ := NewGzipEncoder(gzip.DefaultCompression)
+	fl, _ := NewDeflateEncoder(zlib.DefaultCompression)
+	return NewEncoderRegistry(gz, fl)
+}
+
+// Register adds an encoder with the lowest preference. An encoder already
+// registered for the same content-coding is replaced, keeping its preference.
+func (r *EncoderRegistry) Register(e Encoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, v := range r.encoders {
+		if v.Encoding() == e.Encoding() {
+			r.encoders[i] = e
+			return
+		}
+	}
+	r.encoders = append(r.encoders, e)
+}
+
+// Negotiate returns the encoder to use for a given Accept-Encoding header
+// value, or nil if the response should not be compressed. The encoder with
+// the highest qvalue is chosen; ties are broken by server preference.
+//
+// See: https://tools.ietf.org/html/rfc7231#section-5.3.4
+func (r *EncoderRegistry) Negotiate(accept string) Encoder {
+	if strings.TrimSpace(accept) == "" {
+		return nil
+	}
+	acceptedEncodings, _ := parseEncodings(accept)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best Encoder
+	bestQ := 0.0
+	for _, e := range r.encoders {
+		q, ok := acceptedEncodings[e.Encoding()]
+		if !ok {
+			q = acceptedEncodings["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	if q, ok := acceptedEncodings["identity"]; ok && q > bestQ {
+		return nil
+	}
+	return best
+}
+
+// DefaultMinSize is the default minimum size of response bodies to compress.
+// Smaller bodies hardly shrink, but still cost compressing.
+const DefaultMinSize = 1024
+
+// DefaultExcludedContentTypes lists media types which are already compressed.
+var DefaultExcludedContentTypes = []string{
+	"image/gif", "image/jpeg", "image/png", "image/webp", "image/avif",
+	"audio/*", "video/*", "font/woff", "font/woff2",
+	"application/gzip", "application/x-gzip", "application/zip",
+	"application/zstd", "application/x-bzip2", "application/x-xz",
+	"application/x-7z-compressed", "application/x-rar-compressed",
+}
+
+// Compressor compresses response bodies with the best encoding the client
+// supports (via the Accept-Encoding header). Responses to HEAD requests,
+// responses without body, responses already carrying a Content-Encoding and
+// websocket upgrades are not compressed.
+type Compressor struct {
+	// Encoders holds the available encoders. If nil, DefaultEncoders is used.
+	Encoders *EncoderRegistry
+	// MinSize is the minimum size of a response body to be compressed. Up to
+	// MinSize bytes are buffered to decide, unless a Content-Length header is
+	// set.
+	MinSize int
+	// ContentTypes lists the media types to compress. Entries may use a
+	// wildcard subtype, e.g. "text/*". If empty, all media types are
+	// compressed, except those in ExcludedContentTypes.
+	ContentTypes []string
+	// ExcludedContentTypes lists media types never to compress, in the same
+	// format as ContentTypes.
+	ExcludedContentTypes []string
+}
+
+// Compress returns a wrapper function (often known as middleware) which can
+// be used to wrap an HTTP handler to transparently compress the response body
+// with the best encoding from the registry the client supports (via the
+// Accept-Encoding header). Bodies smaller than DefaultMinSize and media types
+// in DefaultExcludedContentTypes are not compressed. If registry is nil,
+// DefaultEncoders is used.
+func Compress(registry *EncoderRegistry) func(http.Handler) http.Handler {
+	c := &Compressor{
+		Encoders:             registry,
+		MinSize:              DefaultMinSize,
+		ExcludedContentTypes: DefaultExcludedContentTypes,
+	}
+	return c.Handler
+}
+
+// Handler wraps an HTTP handler to compress its responses.
+func (c *Compressor) Handler(h http.Handler) http.Handler {
+	registry := c.Encoders
+	if registry == nil {
+		registry = DefaultEncoders()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(vary, acceptEncoding)
+
+		if _, ok := r.Header["Upgrade"]; ok || r.Method == http.MethodHead {
+			// Avoid compressing websockets due to handling of closing and
+			// flushing of connections.
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		encoder := registry.Negotiate(r.Header.Get(acceptEncoding))
+		if encoder == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{responseWriter: responseWriter{w}, c: c, encoder: encoder}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressible reports whether a media type should be compressed.
+func (c *Compressor) compressible(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if len(c.ContentTypes) > 0 && !matchMediaType(c.ContentTypes, mediaType) {
+		return false
+	}
+	return !matchMediaType(c.ExcludedContentTypes, mediaType)
+}
+
+func matchMediaType(patterns []string, mediaType string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == mediaType || strings.HasSuffix(p, "/*") && strings.HasPrefix(mediaType, p[:len(p)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponseWriter provides an http.ResponseWriter interface, which
+// buffers the beginning of the body to decide whether to compress it, and
+// then compresses bytes before writing them to the underlying response.
+type compressResponseWriter struct {
+	responseWriter
+	c       *Compressor
+	encoder Encoder
+
+	buf      []byte
+	status   int
+	decided  bool
+	hijacked bool
+	cw       io.WriteCloser // nil unless compressing
+}
+
+// WriteHeader records the status code, which is sent along with the headers
+// once it is decided whether to compress.
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.decided || code >= 100 && code < 200 {
+		// Informational responses are sent right away.
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status != 0 {
+		// Superfluous calls are ignored, like by net/http.
+		return
+	}
+
+	w.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+		return
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.c.MinSize {
+			w.decide(false)
+		}
+	}
+}
+
+// Write buffers data until it is decided whether to compress, and then writes
+// to the compressing writer or the underlying response.
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.c.MinSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide decides whether to compress, sends the headers and writes the
+// buffered data. The body is compressed if large is true and the response
+// qualifies otherwise.
+func (w *compressResponseWriter) decide(large bool) error {
+	w.decided = true
+	h := w.Header()
+
+	if _, ok := h["Content-Type"]; !ok && len(w.buf) > 0 {
+		// If content type is not set, infer it from the uncompressed body.
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if large && h.Get(contentEncoding) == "" && w.c.compressible(h.Get("Content-Type")) {
+		h.Set(contentEncoding, w.encoder.Encoding())
+		// The length of the compressed body is not known in advance.
+		h.Del("Content-Length")
+		// The compressed body is not byte-for-byte the same representation.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.cw = w.encoder.NewWriter(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// close decides on still buffered data and closes the compressing writer.
+func (w *compressResponseWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		w.decide(len(w.buf) >= w.c.MinSize && len(w.buf) > 0)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
+
+// Flush decides on buffered data, flushes the compressing writer and then the
+// underlying http.ResponseWriter if it is an http.Flusher.
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.c.MinSize && len(w.buf) > 0)
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.responseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does.
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.responseWriter.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// ReadFrom implements the io.ReaderFrom interface. Uncompressed responses are
+// passed on to the underlying http.ResponseWriter if it is an io.ReaderFrom,
+// allowing it to use sendfile.
+func (w *compressResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.decided && w.cw == nil {
+		return w.readFrom(w, r)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	registry := DefaultEncoders()
+	registry.Register(NewEncoder("br", func() ResetWriter {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	}))
+
+	tests := []struct {
+		accept, expected string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"deflate;q=1.0, gzip;q=0.5", "deflate"},
+		{"br, gzip;q=0.8", "br"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.5, gzip;q=0", "deflate"},
+		{"identity, gzip;q=0.5", ""},
+		{"compress", ""},
+	}
+
+	for _, tt := range tests {
+		actual := ""
+		if e := registry.Negotiate(tt.accept); e != nil {
+			actual = e.Encoding()
+		}
+		if actual != tt.expected {
+			t.Fatalf("%q: %q != %q", tt.accept, tt.expected, actual)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("hello world ", 100)
+	h := Compress(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+
+	for _, encoding := range []string{"gzip", "deflate", ""} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if actual := w.Header().Get("Content-Encoding"); actual != encoding {
+			t.Fatalf("%q != %q", encoding, actual)
+		}
+		if actual := w.Header().Get("Vary"); actual != "Accept-Encoding" {
+			t.Fatalf("unexpected Vary header %q", actual)
+		}
+
+		var rd io.Reader = w.Body
+		switch encoding {
+		case "gzip":
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rd = gr
+		case "deflate":
+			zr, err := zlib.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rd = zr
+		}
+		actual, err := ioutil.ReadAll(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, []byte(body)) {
+			t.Fatalf("%s: unexpected body %q", encoding, actual)
+		}
+	}
+}
+
+func TestNewGzipLevelHandler(t *testing.T) {
+	if _, err := NewGzipLevelHandler(42); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := NewDeflateEncoder(flate.BestSpeed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressThresholds(t *testing.T) {
+	large := strings.Repeat("a", DefaultMinSize)
+	tests := []struct {
+		name        string
+		method      string
+		status      int
+		contentType string
+		body        string
+		compressed  bool
+	}{
+		{"large", "GET", 200, "", large, true},
+		{"small", "GET", 200, "", "small", false},
+		{"image", "GET", 200, "image/png", large, false},
+		{"svg", "GET", 200, "image/svg+xml", large, true},
+		{"head", "HEAD", 200, "", large, false},
+		{"not modified", "GET", 304, "", "", false},
+		{"no content", "GET", 204, "", "", false},
+	}
+
+	for _, tt := range tests {
+		h := GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.contentType != "" {
+				w.Header().Set("Content-Type", tt.contentType)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+			w.WriteHeader(tt.status)
+			if r.Method != "HEAD" {
+				// Write in pieces to exercise buffering.
+				io.WriteString(w, tt.body[:len(tt.body)/2])
+				io.WriteString(w, tt.body[len(tt.body)/2:])
+			}
+		}))
+
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s: %d != %d", tt.name, tt.status, w.Code)
+		}
+		compressed := w.Header().Get("Content-Encoding") == "gzip"
+		if compressed != tt.compressed {
+			t.Fatalf("%s: expected compressed %t", tt.name, tt.compressed)
+		}
+		if compressed && w.Header().Get("Content-Length") != "" {
+			t.Fatalf("%s: unexpected Content-Length", tt.name)
+		}
+		expected := tt.body
+		if tt.method == "HEAD" {
+			expected = ""
+		}
+		if !compressed && w.Body.String() != expected {
+			t.Fatalf("%s: unexpected body %q", tt.name, w.Body.String())
+		}
+	}
+}
+
+func TestCompressContentTypes(t *testing.T) {
+	c := &Compressor{ContentTypes: []string{"text/*", "application/json"}, ExcludedContentTypes: []string{"text/event-stream"}}
+	for contentType, expected := range map[string]bool{
+		"text/html; charset=utf-8": true,
+		"Application/JSON":         true,
+		"text/event-stream":        false,
+		"image/svg+xml":            false,
+		"":                         false,
+	} {
+		if c.compressible(contentType) != expected {
+			t.Fatalf("%q: expected %t", contentType, expected)
+		}
+	}
+}
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed string
+}
+
+func (r *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = target
+	return nil
+}
+
+func TestCompressInterfaces(t *testing.T) {
+	h := GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Fatal("expected http.Hijacker")
+		}
+		if err := w.(http.Pusher).Push("/style.css", nil); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader(strings.Repeat("a", 2*DefaultMinSize))); err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, r)
+
+	if w.pushed != "/style.css" {
+		t.Fatalf("unexpected push %q", w.pushed)
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(gr)
+	if err != nil || len(body) != 2*DefaultMinSize {
+		t.Fatalf("unexpected body of %d bytes: %v", len(body), err)
+	}
+}
@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures cross-origin resource sharing.
+//
+// See: https://fetch.spec.whatwg.org/#http-cors-protocol
+type CORSConfig struct {
+	// AllowedOrigins lists the allowed origins. Entries are exact origins
+	// such as "https://example.com", origins with a wildcard subdomain such
+	// as "https://*.example.com", which matches subdomains of any depth but
+	// not the domain itself, or "*" to allow any origin.
+	AllowedOrigins []string
+	// AllowOriginFunc, if set, allows origins for which it returns true, in
+	// addition to AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods lists the allowed methods. If empty, GET, HEAD and POST
+	// are listed. These CORS-safelisted methods are always allowed.
+	AllowedMethods []string
+	// AllowedHeaders lists the allowed request headers, or "*" to allow any.
+	// CORS-safelisted request headers such as Content-Type are always
+	// allowed.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers exposed to scripts, or "*"
+	// to expose all.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with credentials such as cookies. It
+	// cannot be combined with wildcards.
+	AllowCredentials bool
+	// MaxAge is how long preflight results may be cached. If zero, the
+	// header is omitted and browsers use their default of 5 seconds.
+	MaxAge time.Duration
+}
+
+// CORS handles cross-origin requests as configured by a CORSConfig.
+type CORS struct {
+	anyOrigin      bool
+	origins        map[string]bool
+	subdomains     []originPattern
+	originFunc     func(string) bool
+	methods        []string
+	anyHeader      bool
+	headers        map[string]bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// originPattern matches subdomains of any depth of a host.
+type originPattern struct {
+	scheme, suffix, port string
+}
+
+var corsSafelistedHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+
+// NewCORS validates a configuration and returns the CORS middleware. An error
+// is returned for malformed origins and for wildcards combined with
+// credentials, which browsers reject.
+func NewCORS(config CORSConfig) (*CORS, error) {
+	c := &CORS{
+		origins:     make(map[string]bool),
+		originFunc:  config.AllowOriginFunc,
+		methods:     append([]string(nil), config.AllowedMethods...),
+		headers:     make(map[string]bool),
+		credentials: config.AllowCredentials,
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			if config.AllowCredentials {
+				return nil, fmt.Errorf("wildcard origin cannot be combined with credentials")
+			}
+			c.anyOrigin = true
+		case origin == "null":
+			c.origins[origin] = true
+		default:
+			u, err := url.Parse(origin)
+			if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+				return nil, fmt.Errorf("invalid origin %q", origin)
+			}
+			host := u.Hostname()
+			if strings.HasPrefix(host, "*.") {
+				if strings.Contains(host[2:], "*") {
+					return nil, fmt.Errorf("invalid origin %q", origin)
+				}
+				c.subdomains = append(c.subdomains, originPattern{u.Scheme, host[1:], u.Port()})
+			} else if strings.Contains(host, "*") {
+				return nil, fmt.Errorf("invalid origin %q", origin)
+			} else {
+				c.origins[origin] = true
+			}
+		}
+	}
+
+	if len(c.methods) == 0 {
+		c.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	for i, m := range c.methods {
+		if m == "" || strings.ContainsAny(m, " ,*") {
+			return nil, fmt.Errorf("invalid method %q", m)
+		}
+		c.methods[i] = strings.ToUpper(m)
+	}
+
+	for _, h := range config.AllowedHeaders {
+		if h == "*" {
+			if config.AllowCredentials {
+				return nil, fmt.Errorf("wildcard header cannot be combined with credentials")
+			}
+			c.anyHeader = true
+			continue
+		}
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, h := range corsSafelistedHeaders {
+		c.headers[h] = true
+	}
+
+	for _, h := range config.ExposedHeaders {
+		if h == "*" && config.AllowCredentials {
+			return nil, fmt.Errorf("wildcard exposed header cannot be combined with credentials")
+		}
+	}
+	c.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+
+	if config.MaxAge < 0 {
+		return nil, fmt.Errorf("negative max age %s", config.MaxAge)
+	}
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	}
+
+	return c, nil
+}
+
+// MustNewCORS behaves just like NewCORS except that in an error case it
+// panics rather than returning an error.
+func MustNewCORS(config CORSConfig) *CORS {
+	c, err := NewCORS(config)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Handler wraps an HTTP handler to handle cross-origin requests. Preflight
+// requests are answered without calling the handler.
+func (c *CORS) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !c.anyOrigin {
+			// The response depends on the origin, even if there is none.
+			header.Add(vary, "Origin")
+		}
+		if preflight {
+			header.Add(vary, "Access-Control-Request-Method")
+			header.Add(vary, "Access-Control-Request-Headers")
+		}
+
+		if origin == "" || !c.allowOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if preflight {
+			c.handlePreflight(w, r, origin)
+			return
+		}
+
+		c.setOrigin(header, origin)
+		if c.exposedHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	header := w.Header()
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !c.allowMethod(method) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var requested []string
+	for _, v := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				if !c.anyHeader && !c.headers[http.CanonicalHeaderKey(h)] {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				requested = append(requested, h)
+			}
+		}
+	}
+
+	c.setOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) setOrigin(header http.Header, origin string) {
+	if c.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if c.origins[lower] {
+		return true
+	}
+	if len(c.subdomains) > 0 {
+		if u, err := url.Parse(lower); err == nil {
+			for _, p := range c.subdomains {
+				if u.Scheme == p.scheme && u.Port() == p.port && strings.HasSuffix(u.Hostname(), p.suffix) &&
+					len(u.Hostname()) > len(p.suffix) {
+					return true
+				}
+			}
+		}
+	}
+	return c.originFunc != nil && c.originFunc(origin)
+}
+
+func (c *CORS) allowMethod(method string) bool {
+	// CORS-safelisted methods are always allowed.
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodPost {
+		return true
+	}
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
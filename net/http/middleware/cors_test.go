@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCORS(t *testing.T) {
+	for _, config := range []CORSConfig{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"https://example.com"}, AllowedHeaders: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"https://example.com/"}},
+		{AllowedOrigins: []string{"example.com"}},
+		{AllowedOrigins: []string{"https://api.*.example.com"}},
+		{AllowedOrigins: []string{"https://example.com"}, MaxAge: -time.Second},
+	} {
+		if _, err := NewCORS(config); err == nil {
+			t.Fatalf("%+v: expected error", config)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	c := MustNewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return strings.HasSuffix(origin, ".test") },
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+	calls := 0
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	tests := []struct {
+		method, origin, requestMethod, requestHeaders string
+		allowed                                       bool
+		calls                                         int
+	}{
+		{"GET", "", "", "", false, 1},
+		{"GET", "https://example.com", "", "", true, 1},
+		{"GET", "https://evil.com", "", "", false, 1},
+		{"GET", "https://a.b.example.org", "", "", true, 1},
+		{"GET", "https://example.org", "", "", false, 1},
+		{"GET", "http://a.example.org", "", "", false, 1},
+		{"GET", "http://localhost.test", "", "", true, 1},
+		{"OPTIONS", "https://example.com", "PUT", "authorization, content-type", true, 0},
+		{"OPTIONS", "https://example.com", "DELETE", "", false, 0},
+		{"OPTIONS", "https://example.com", "POST", "", true, 0},
+		{"OPTIONS", "https://example.com", "PUT", "X-Secret", false, 0},
+		{"OPTIONS", "https://example.com", "", "", true, 1},
+	}
+
+	for _, tt := range tests {
+		calls = 0
+		r := httptest.NewRequest(tt.method, "/", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.requestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		if tt.requestHeaders != "" {
+			r.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		name := tt.method + " " + tt.origin + " " + tt.requestMethod
+		allowed := w.Header().Get("Access-Control-Allow-Origin") != ""
+		if allowed != tt.allowed || calls != tt.calls {
+			t.Fatalf("%s: expected allowed %t after %d calls", name, tt.allowed, tt.calls)
+		}
+		if w.Header().Get("Vary") != "Origin" && tt.requestMethod == "" {
+			t.Fatalf("%s: unexpected Vary %q", name, w.Header()["Vary"])
+		}
+		if !allowed {
+			continue
+		}
+		if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Fatalf("%s: expected credentials", name)
+		}
+		if tt.requestMethod != "" {
+			if w.Code != 204 || w.Header().Get("Access-Control-Allow-Methods") != "GET, PUT" ||
+				w.Header().Get("Access-Control-Allow-Headers") != tt.requestHeaders ||
+				w.Header().Get("Access-Control-Max-Age") != "3600" {
+				t.Fatalf("%s: unexpected preflight response %d %v", name, w.Code, w.Header())
+			}
+		} else if w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+			t.Fatalf("%s: expected exposed headers", name)
+		}
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	h := MustNewCORS(CORSConfig{AllowedOrigins: []string{"*"}}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Vary") != "" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+}
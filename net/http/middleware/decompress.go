@@ -0,0 +1,312 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxDecompressedSize is the default limit of decompressed request
+// bodies.
+const DefaultMaxDecompressedSize = 10 << 20
+
+// gzipReaderPool stores gzip.Readers for re-use. Readers can only be created
+// from a valid gzip stream, so the pool has no New function.
+var gzipReaderPool sync.Pool
+
+// zlibReaderPool stores zlib readers for re-use, like gzipReaderPool.
+var zlibReaderPool sync.Pool
+
+// flateReaderPool stores flate readers for re-use.
+var flateReaderPool = sync.Pool{
+	New: func() interface{} {
+		return flate.NewReader(nil)
+	},
+}
+
+// Decompress returns a wrapper function (often known as middleware) which can
+// be used to wrap an HTTP handler to transparently decompress request bodies
+// sent with Content-Encoding gzip or deflate. Other encodings are rejected
+// with 415 Unsupported Media Type, and streams with malformed headers with 400
+// Bad Request.
+//
+// Request bodies are decompressed while the handler reads them, so their
+// length is unknown. Reading fails with an *http.MaxBytesError once the
+// compressed or decompressed body exceeds maxSize, and with the decoder's
+// error on malformed data. If reading failed before the handler wrote its
+// response header, the response is replaced with 413 Request Entity Too Large
+// or 400 Bad Request, respectively. If maxSize <= 0,
+// DefaultMaxDecompressedSize is used.
+func Decompress(maxSize int64) func(http.Handler) http.Handler {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(contentEncoding) == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			coding, ok := contentCoding(r.Header.Get(contentEncoding))
+			if ok && coding == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if !ok || (coding != "gzip" && coding != "x-gzip" && coding != "deflate") {
+				// See: https://tools.ietf.org/html/rfc7694#section-3
+				w.Header().Set(acceptEncoding, "gzip, deflate")
+				http.Error(w, "unsupported content encoding "+r.Header.Get(contentEncoding),
+					http.StatusUnsupportedMediaType)
+				return
+			}
+			if r.ContentLength > maxSize {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge),
+					http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			body, err := newDecompressBody(coding, r.Body, http.MaxBytesReader(w, r.Body, maxSize), maxSize)
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				r.Body.Close()
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge),
+					http.StatusRequestEntityTooLarge)
+				return
+			case err != nil:
+				r.Body.Close()
+				http.Error(w, "malformed "+coding+" request body", http.StatusBadRequest)
+				return
+			}
+			defer body.Close()
+
+			dw := &decompressResponseWriter{responseWriter: responseWriter{w}, body: body}
+			r.Body = body
+			r.Header.Del(contentEncoding)
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			h.ServeHTTP(dw, r)
+			dw.finish()
+		})
+	}
+}
+
+// contentCoding returns the content-coding of a Content-Encoding header value,
+// ignoring identity, and whether it is a single valid one.
+func contentCoding(s string) (string, bool) {
+	encodings, err := parseEncodings(s)
+	if err != nil {
+		return "", false
+	}
+	delete(encodings, "identity")
+	if len(encodings) > 1 {
+		return "", false
+	}
+	for coding := range encodings {
+		return coding, true
+	}
+	return "", true
+}
+
+// decompressBody is a request body decompressed while it is read, limited to
+// a maximum size.
+type decompressBody struct {
+	rd      io.Reader // nil once closed
+	raw     io.Closer
+	release func() // returns the decoder to its pool
+	n, max  int64
+
+	status int32 // status code to respond with if reading failed
+}
+
+// newDecompressBody returns a body decompressing src, the limited raw body,
+// using pooled readers. Closing it closes raw. Headers of the compressed
+// stream are read right away.
+func newDecompressBody(coding string, raw io.Closer, src io.Reader, maxSize int64) (*decompressBody, error) {
+	b := &decompressBody{raw: raw, max: maxSize}
+	switch coding {
+	case "gzip", "x-gzip":
+		zr, ok := gzipReaderPool.Get().(*gzip.Reader)
+		var err error
+		if ok {
+			err = zr.Reset(src)
+		} else {
+			zr, err = gzip.NewReader(src)
+		}
+		if err != nil {
+			if ok {
+				gzipReaderPool.Put(zr)
+			}
+			return nil, err
+		}
+		b.rd = zr
+		b.release = func() { gzipReaderPool.Put(zr) }
+
+	case "deflate":
+		// Some clients send raw deflate streams instead of the zlib format
+		// required by RFC 7230, so sniff the zlib header.
+		br := bufio.NewReader(src)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, ok := zlibReaderPool.Get().(io.ReadCloser)
+			if ok {
+				err = zr.(zlib.Resetter).Reset(br, nil)
+			} else {
+				zr, err = zlib.NewReader(br)
+			}
+			if err != nil {
+				if ok {
+					zlibReaderPool.Put(zr)
+				}
+				return nil, err
+			}
+			b.rd = zr
+			b.release = func() { zlibReaderPool.Put(zr) }
+			break
+		}
+		fr := flateReaderPool.Get().(io.ReadCloser)
+		fr.(flate.Resetter).Reset(br, nil)
+		b.rd = fr
+		b.release = func() { flateReaderPool.Put(fr) }
+	}
+	return b, nil
+}
+
+// Read decompresses up to maxSize bytes, failing with an *http.MaxBytesError
+// beyond.
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.rd == nil {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	remaining := b.max - b.n
+	if int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := b.rd.Read(p)
+	if int64(n) > remaining {
+		b.n = b.max
+		b.fail(http.StatusRequestEntityTooLarge)
+		return int(remaining), &http.MaxBytesError{Limit: b.max}
+	}
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			b.fail(http.StatusRequestEntityTooLarge)
+		} else {
+			b.fail(http.StatusBadRequest)
+		}
+	}
+	return n, err
+}
+
+// Close returns the decoder to its pool and closes the raw body.
+func (b *decompressBody) Close() error {
+	if b.rd == nil {
+		return nil
+	}
+	b.rd = nil
+	b.release()
+	return b.raw.Close()
+}
+
+// fail records the status code to respond with, keeping the first one.
+func (b *decompressBody) fail(code int) {
+	atomic.CompareAndSwapInt32(&b.status, 0, int32(code))
+}
+
+// decompressResponseWriter replaces the handler's response with an error if
+// reading the request body failed before the response header was written.
+type decompressResponseWriter struct {
+	responseWriter
+	body        *decompressBody
+	wroteHeader bool
+	discard     bool // the response was replaced
+}
+
+func (w *decompressResponseWriter) WriteHeader(code int) {
+	if w.discard {
+		return
+	}
+	if !w.wroteHeader && code >= 200 {
+		w.wroteHeader = true
+		if w.replace() {
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *decompressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying http.ResponseWriter if it is an http.Flusher.
+func (w *decompressResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.responseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does.
+func (w *decompressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.responseWriter.Hijack()
+	if err == nil {
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// ReadFrom implements the io.ReaderFrom interface, passing on to the
+// underlying http.ResponseWriter if it is an io.ReaderFrom.
+func (w *decompressResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return io.Copy(ioutil.Discard, r)
+	}
+	return w.readFrom(w, r)
+}
+
+// finish replaces an empty response once the handler returned.
+func (w *decompressResponseWriter) finish() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.replace()
+	}
+}
+
+// replace responds with an error if reading the request body failed.
+func (w *decompressResponseWriter) replace() bool {
+	code := int(atomic.LoadInt32(&w.body.status))
+	if code == 0 {
+		return false
+	}
+	w.discard = true
+	http.Error(w.ResponseWriter, http.StatusText(code), code)
+	return true
+}
+
+// isZlibHeader reports whether b starts with a zlib header using the deflate
+// compression method.
+//
+// See: https://tools.ietf.org/html/rfc1950#section-2.2
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
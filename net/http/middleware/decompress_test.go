@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func compressed(s string, newWriter func(io.Writer) io.WriteCloser) []byte {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	payload := strings.Repeat(`{"hello":"world"}`, 100)
+	newGzip := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	newZlib := func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	newFlate := func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}
+
+	h := Decompress(int64(len(payload)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e := r.Header.Get("Content-Encoding"); e != "" && e != "identity" {
+			t.Fatal("unexpected Content-Encoding")
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			// Decompress replaces the response.
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{"", []byte(payload), 200},
+		{"identity", []byte(payload), 200},
+		{"gzip", compressed(payload, newGzip), 200},
+		{"deflate", compressed(payload, newZlib), 200},
+		{"deflate", compressed(payload, newFlate), 200},
+		{"br", []byte(payload), 415},
+		{"gzip, deflate", compressed(payload, newGzip), 415},
+		{"gzip", []byte(payload), 400},
+		{"gzip", compressed(payload+"!", newGzip), 413},
+		{"deflate", compressed(payload+"!", newZlib), 413},
+		{"gzip", compressed(payload, newGzip)[:20], 400},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+		r.Header.Set("Content-Encoding", tt.encoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%q: %d != %d: %s", tt.encoding, tt.status, w.Code, w.Body)
+		}
+		if tt.status == 415 && w.Header().Get("Accept-Encoding") == "" {
+			t.Fatalf("%q: expected Accept-Encoding header", tt.encoding)
+		}
+		if tt.status == 200 && w.Body.String() != payload {
+			t.Fatalf("%q: unexpected body %q", tt.encoding, w.Body)
+		}
+	}
+}
+
+func TestDecompressBomb(t *testing.T) {
+	const maxSize = 1 << 20
+	bomb := compressed(strings.Repeat("0", 100*maxSize), func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	})
+	if len(bomb) > maxSize {
+		t.Fatalf("payload not compressible enough: %d bytes", len(bomb))
+	}
+
+	var n int64
+	h := Decompress(maxSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		n, err = io.Copy(ioutil.Discard, r.Body)
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &maxBytesErr) {
+			t.Fatalf("unexpected error %v", err)
+		}
+		io.WriteString(w, "ok")
+	}))
+
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(bomb))
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge || n != maxSize {
+		t.Fatalf("unexpected response %d after reading %d bytes", w.Code, n)
+	}
+}
+
+func TestDecompressStreaming(t *testing.T) {
+	pr, pw := io.Pipe()
+	read := make(chan struct{})
+	go func() {
+		zw := gzip.NewWriter(pw)
+		io.WriteString(zw, "hello")
+		zw.Flush()
+		select {
+		case <-read:
+		case <-time.After(time.Second):
+			pw.CloseWithError(errors.New("request body was buffered"))
+			return
+		}
+		io.WriteString(zw, " world")
+		zw.Close()
+		pw.Close()
+	}()
+
+	h := Decompress(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != -1 || r.Header.Get("Content-Length") != "" {
+			t.Fatalf("unexpected content length %d", r.ContentLength)
+		}
+		head := make([]byte, 5)
+		if _, err := io.ReadFull(r.Body, head); err != nil {
+			t.Fatal(err)
+		}
+		close(read)
+		tail, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(append(head, tail...))
+	}))
+
+	r := httptest.NewRequest("POST", "/", pr)
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Body.String() != "hello world" {
+		t.Fatalf("unexpected response %d: %q", w.Code, w.Body)
+	}
+}
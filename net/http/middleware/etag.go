@@ -0,0 +1,321 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultETagMaxSize is the default maximum size of response bodies buffered
+// to compute an ETag.
+const DefaultETagMaxSize = 1 << 20
+
+// ValidatorFunc returns the current validators of the resource a request
+// targets: an entity tag, including its quotes, and the modification time. An
+// empty entity tag and the zero time denote unknown validators.
+type ValidatorFunc func(r *http.Request) (etag string, lastModified time.Time)
+
+// ETagger computes entity tags of responses and answers conditional requests
+// as defined by RFC 7232: If-None-Match and If-Modified-Since with 304 Not
+// Modified, and If-Match and If-Unmodified-Since with 412 Precondition Failed.
+//
+// Entity tags set by handlers via the ETag header are kept. Otherwise an ETag
+// is computed by hashing the response body of successful GET and HEAD
+// requests. Responses with a Content-Encoding, e.g. when wrapping GzipHandler,
+// get weak entity tags, as do responses compressed by Compress.
+type ETagger struct {
+	// Weak makes computed entity tags weak, e.g. for handlers whose output
+	// is semantically but not byte-for-byte stable.
+	Weak bool
+	// MaxSize is the maximum size of response bodies buffered to compute an
+	// entity tag. Larger responses are sent without. If zero,
+	// DefaultETagMaxSize is used.
+	MaxSize int
+	// Validators, if set, provides the validators of the current resource.
+	// They are evaluated before calling the handler, which is required for
+	// preconditions of state changing requests such as PUT with If-Match, and
+	// spares generating responses to GET requests only to answer 304. Without
+	// Validators, state changing requests with If-Match or
+	// If-Unmodified-Since are answered with 412 Precondition Failed, as their
+	// preconditions cannot be evaluated.
+	Validators ValidatorFunc
+}
+
+// ETag wraps an HTTP handler to compute strong entity tags of its responses
+// and answer conditional requests, as described by ETagger.
+func ETag(h http.Handler) http.Handler {
+	return (&ETagger{}).Handler(h)
+}
+
+// Handler wraps an HTTP handler to compute entity tags of its responses and
+// answer conditional requests.
+func (e *ETagger) Handler(h http.Handler) http.Handler {
+	maxSize := e.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultETagMaxSize
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.Validators != nil {
+			etag, lastModified := e.Validators(r)
+			if status := checkPreconditions(r, etag, lastModified); status != 0 {
+				if etag != "" {
+					w.Header().Set("ETag", etag)
+				}
+				writeConditionalStatus(w, status)
+				return
+			}
+			// Validators of a resource about to be changed do not apply to
+			// the response.
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				if etag != "" {
+					w.Header().Set("ETag", etag)
+				}
+				if !lastModified.IsZero() {
+					w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+				}
+			}
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if e.Validators == nil && (r.Header.Get("If-Match") != "" || r.Header.Get("If-Unmodified-Since") != "") {
+				writeConditionalStatus(w, http.StatusPreconditionFailed)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ew := &etagResponseWriter{responseWriter: responseWriter{w}, e: e, r: r, maxSize: maxSize}
+		h.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// etagResponseWriter buffers a response to compute its entity tag and
+// evaluate the request's preconditions before sending it.
+type etagResponseWriter struct {
+	responseWriter
+	e       *ETagger
+	r       *http.Request
+	maxSize int
+
+	buf         bytes.Buffer
+	status      int
+	passthrough bool // the response is sent as is
+	discard     bool // the response was answered with a conditional status
+}
+
+// WriteHeader records the status code. Unsuccessful responses are sent as is.
+// If the handler set an ETag header, preconditions are evaluated right away.
+func (w *etagResponseWriter) WriteHeader(code int) {
+	if w.passthrough || code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status != 0 || w.discard {
+		return
+	}
+
+	w.status = code
+	if code != http.StatusOK {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if etag := w.Header().Get("ETag"); etag != "" && !w.conclude(etag) {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// Write buffers data until the response is complete or exceeds the maximum
+// size.
+func (w *etagResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(b), nil
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf.Len()+len(b) > w.maxSize {
+		if w.conclude(w.Header().Get("ETag")) {
+			return len(b), nil
+		}
+		if err := w.stream(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// Flush sends the response as is, unless the preconditions evaluated against
+// the validators set so far yield a conditional status, and flushes the
+// underlying http.ResponseWriter if it is an http.Flusher.
+func (w *etagResponseWriter) Flush() {
+	if !w.passthrough && !w.discard {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if w.conclude(w.Header().Get("ETag")) {
+			return
+		}
+		w.stream()
+	}
+	w.responseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does.
+func (w *etagResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.responseWriter.Hijack()
+	if err == nil {
+		w.passthrough = true
+	}
+	return conn, rw, err
+}
+
+// stream gives up on computing an entity tag and sends the buffered response.
+func (w *etagResponseWriter) stream() error {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// finish computes the entity tag of a buffered response, if not set by the
+// handler, and sends the response or answers with a conditional status.
+func (w *etagResponseWriter) finish() {
+	if w.passthrough || w.discard {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	etag := w.Header().Get("ETag")
+	// Handlers usually answer HEAD requests without body, whose entity tag
+	// would differ from the one of the GET response.
+	if etag == "" && (w.buf.Len() > 0 || w.r.Method != http.MethodHead) {
+		weak := w.e.Weak || w.Header().Get(contentEncoding) != ""
+		etag = computeETag(w.buf.Bytes(), weak)
+		w.Header().Set("ETag", etag)
+	}
+	if w.conclude(etag) {
+		return
+	}
+	w.stream()
+}
+
+// conclude evaluates the request's preconditions, if any validators are
+// known. If they yield a conditional status, it is sent, the response body
+// discarded and true returned. Otherwise the response is sent as is once
+// complete.
+func (w *etagResponseWriter) conclude(etag string) bool {
+	var lastModified time.Time
+	if lm := w.Header().Get("Last-Modified"); lm != "" {
+		lastModified, _ = http.ParseTime(lm)
+	}
+	if etag == "" && lastModified.IsZero() {
+		return false
+	}
+
+	status := checkPreconditions(w.r, etag, lastModified)
+	if status == 0 {
+		return false
+	}
+	w.discard = true
+	w.buf.Reset()
+	writeConditionalStatus(w.ResponseWriter, status)
+	return true
+}
+
+// computeETag returns an entity tag derived from the SHA-256 hash of b.
+func computeETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// checkPreconditions evaluates the conditional headers of a request against
+// the validators of the selected representation. It returns the status to
+// respond with, or 0 if the request should be processed.
+//
+// See: https://tools.ietf.org/html/rfc7232#section-6
+func checkPreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	lastModified = lastModified.Truncate(time.Second)
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && safe && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag reports whether a list of entity tags, as found in an If-Match or
+// If-None-Match header, matches etag. "*" matches any existing entity tag.
+// Weak comparison ignores the weakness indicator; strong comparison requires
+// both entity tags to be strong.
+func matchETag(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditionalStatus writes a 304 or 412 response without body.
+func writeConditionalStatus(w http.ResponseWriter, status int) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del(contentEncoding)
+	if status == http.StatusNotModified && h.Get("ETag") != "" {
+		h.Del("Last-Modified")
+	}
+	w.WriteHeader(status)
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	body := strings.Repeat("hello world ", 10)
+	lastModified := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		io.WriteString(w, body)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || w.Body.String() != body || etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("unexpected response %d %q: %s", w.Code, etag, w.Body)
+	}
+
+	tests := []struct {
+		header, value string
+		status        int
+	}{
+		{"If-None-Match", etag, 304},
+		{"If-None-Match", `"other", W/` + etag, 304},
+		{"If-None-Match", `"other"`, 200},
+		{"If-None-Match", "*", 304},
+		{"If-Modified-Since", lastModified.Format(http.TimeFormat), 304},
+		{"If-Modified-Since", lastModified.Add(-time.Second).Format(http.TimeFormat), 200},
+		{"If-Match", etag, 200},
+		{"If-Match", "W/" + etag, 412},
+		{"If-Unmodified-Since", lastModified.Add(-time.Second).Format(http.TimeFormat), 412},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(tt.header, tt.value)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s: %s: %d != %d", tt.header, tt.value, tt.status, w.Code)
+		}
+		if tt.status != 200 && w.Body.Len() != 0 {
+			t.Fatalf("%s: %s: unexpected body", tt.header, tt.value)
+		}
+		if tt.status == 304 && w.Header().Get("ETag") != etag {
+			t.Fatalf("%s: %s: missing ETag", tt.header, tt.value)
+		}
+	}
+}
+
+func TestETagValidators(t *testing.T) {
+	calls := 0
+	e := &ETagger{Validators: func(r *http.Request) (string, time.Time) {
+		return `"v1"`, time.Time{}
+	}}
+	h := e.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method, header, value string
+		status, calls         int
+	}{
+		{"PUT", "If-Match", `"v1"`, 204, 1},
+		{"PUT", "If-Match", `"v0"`, 412, 0},
+		{"PUT", "If-None-Match", "*", 412, 0},
+		{"GET", "If-None-Match", `"v1"`, 304, 0},
+	}
+
+	for _, tt := range tests {
+		calls = 0
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.Header.Set(tt.header, tt.value)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status || calls != tt.calls {
+			t.Fatalf("%s %s: %s: %d != %d", tt.method, tt.header, tt.value, tt.status, w.Code)
+		}
+	}
+}
+
+func TestETagWithoutValidators(t *testing.T) {
+	calls := 0
+	h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		header, value string
+		status, calls int
+	}{
+		{"", "", 204, 1},
+		{"If-Match", `"v1"`, 412, 0},
+		{"If-Unmodified-Since", time.Now().UTC().Format(http.TimeFormat), 412, 0},
+	}
+
+	for _, tt := range tests {
+		calls = 0
+		r := httptest.NewRequest("PUT", "/", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status || calls != tt.calls {
+			t.Fatalf("%s: %s: %d != %d", tt.header, tt.value, tt.status, w.Code)
+		}
+	}
+}
+
+func TestETagCompressed(t *testing.T) {
+	body := strings.Repeat("hello world ", 1000)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	})
+
+	for name, h := range map[string]http.Handler{
+		"outer": GzipHandler(ETag(handler)),
+		"inner": ETag(GzipHandler(handler)),
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		etag := w.Header().Get("ETag")
+		if !strings.HasPrefix(etag, "W/") {
+			t.Fatalf("%s: expected weak ETag, got %q", name, etag)
+		}
+		if _, err := gzip.NewReader(w.Body); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		r.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != 304 || w.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s: unexpected response %d", name, w.Code)
+		}
+	}
+}
+
+func TestETagHead(t *testing.T) {
+	h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			io.WriteString(w, "hello")
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("HEAD", "/", nil))
+	if etag := w.Header().Get("ETag"); w.Code != 200 || etag != "" {
+		t.Fatalf("unexpected response %d %q", w.Code, etag)
+	}
+}
+
+func TestETagFlush(t *testing.T) {
+	h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "hello")
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", `"v1"`)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body)
+	}
+}
+
+// hijackRecorder is an httptest.ResponseRecorder supporting hijacking, which
+// counts status codes written after the connection was hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked    bool
+	lateHeaders int
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	conn, peer := net.Pipe()
+	peer.Close()
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+func (r *hijackRecorder) WriteHeader(code int) {
+	if r.hijacked {
+		r.lateHeaders++
+		return
+	}
+	r.ResponseRecorder.WriteHeader(code)
+}
+
+// hijackHandler hijacks the connection, e.g. to upgrade it to a WebSocket.
+func hijackHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected http.Hijacker")
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+}
+
+func TestETagHijack(t *testing.T) {
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	ETag(hijackHandler(t)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.hijacked || w.lateHeaders != 0 {
+		t.Fatalf("unexpected hijack %t after %d headers", w.hijacked, w.lateHeaders)
+	}
+}
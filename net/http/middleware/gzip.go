@@ -58,6 +58,9 @@ func addLevelPool(level int) {
 // GzipResponseWriter provides an http.ResponseWriter interface, which gzips
 // bytes before writing them to the underlying response. This doesn't set the
 // Content-Encoding header, nor close the writers, so don't forget to do that.
+//
+// Deprecated: GzipResponseWriter is not used by the middleware anymore and its
+// writer cannot be set outside this package. Use Compressor instead.
 type GzipResponseWriter struct {
 	gw *gzip.Writer
 	http.ResponseWriter
@@ -97,51 +100,26 @@ func MustNewGzipLevelHandler(level int) func(http.Handler) http.Handler {
 // body if the client supports it (via the Accept-Encoding header). Responses will
 // be encoded at the given gzip compression level. An error will be returned only
 // if an invalid gzip compression level is given, so if one can ensure the level
-// is valid, the returned error can be safely ignored.
+// is valid, the returned error can be safely ignored. Like with Compress, bodies
+// smaller than DefaultMinSize and media types in DefaultExcludedContentTypes are
+// sent uncompressed; use a Compressor with a gzip encoder to configure this.
 func NewGzipLevelHandler(level int) (func(http.Handler) http.Handler, error) {
-	if level != gzip.DefaultCompression && (level < gzip.BestSpeed || level > gzip.BestCompression) {
-		return nil, fmt.Errorf("invalid compression level requested: %d", level)
+	encoder, err := NewGzipEncoder(level)
+	if err != nil {
+		return nil, err
 	}
-	return func(h http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Add(vary, acceptEncoding)
-
-			if _, ok := r.Header["Upgrade"]; ok {
-				// Avoid compressing websockets due to handling of closing and
-				// flushing of connections.
-				h.ServeHTTP(w, r)
-			} else if acceptsGzip(r) {
-				// Bytes written during ServeHTTP are redirected to this gzip writer
-				// before being written to the underlying response.
-				gzw := gzipWriterPools[poolIndex(level)].Get().(*gzip.Writer)
-				defer gzipWriterPools[poolIndex(level)].Put(gzw)
-				gzw.Reset(w)
-				defer gzw.Close()
-
-				w.Header().Set(contentEncoding, "gzip")
-				h.ServeHTTP(GzipResponseWriter{gzw, w}, r)
-			} else {
-				h.ServeHTTP(w, r)
-			}
-		})
-	}, nil
+	return Compress(NewEncoderRegistry(encoder)), nil
 }
 
 // GzipHandler wraps an HTTP handler, to transparently gzip the response body if
 // the client supports it (via the Accept-Encoding header). This will compress at
-// the default compression level.
+// the default compression level. Like with NewGzipLevelHandler, small bodies
+// and already compressed media types are sent uncompressed.
 func GzipHandler(h http.Handler) http.Handler {
 	wrapper, _ := NewGzipLevelHandler(gzip.DefaultCompression)
 	return wrapper(h)
 }
 
-// acceptsGzip returns true if the given HTTP request indicates that it will
-// accept a gzippped response.
-func acceptsGzip(r *http.Request) bool {
-	acceptedEncodings, _ := parseEncodings(r.Header.Get(acceptEncoding))
-	return acceptedEncodings["gzip"] > 0.0
-}
-
 // parseEncodings attempts to parse a list of codings, per RFC 2616, as might
 // appear in an Accept-Encoding header. It returns a map of content-codings to
 // quality values, and an error containing the errors encounted. It's probably
@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djui/pkg/net/http/json"
+	timeutil "github.com/djui/pkg/time"
+)
+
+// KeyFunc returns the key a request is rate limited by. Requests with an
+// empty key are not limited.
+type KeyFunc func(r *http.Request) string
+
+// IPKey returns a KeyFunc keying requests by client IP address. If the
+// request comes from one of the trusted proxies, the client IP address is
+// taken from the X-Forwarded-For header: it is the rightmost address not
+// belonging to a trusted proxy. Without trusted proxies, X-Forwarded-For is
+// ignored, as clients can set it arbitrarily.
+func IPKey(trustedProxies ...*net.IPNet) KeyFunc {
+	trusted := func(ip net.IP) bool {
+		for _, n := range trustedProxies {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return host
+		}
+
+		var hops []string
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+		for i := len(hops) - 1; i >= 0 && trusted(ip); i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			ip = hop
+		}
+		return ip.String()
+	}
+}
+
+// HeaderKey returns a KeyFunc keying requests by the value of a header, e.g.
+// an API key.
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// RateLimiter limits the rate of requests per key, using either the token
+// bucket or the sliding window algorithm. Responses carry the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers as proposed by the IETF
+// httpapi working group. Requests over the limit are answered with 429 Too
+// Many Requests as a JSON APIResponse and a Retry-After header.
+//
+// The state of keys which have fully recovered is evicted regularly to bound
+// memory.
+type RateLimiter struct {
+	limit   int
+	period  time.Duration
+	sliding bool
+	key     KeyFunc
+	clock   timeutil.Clock
+
+	mu        sync.Mutex
+	states    map[string]*rateState
+	lastSweep time.Time
+}
+
+type rateState struct {
+	// Token bucket.
+	tokens float64
+	last   time.Time
+	// Sliding window.
+	start      time.Time
+	prev, curr int
+}
+
+// RateLimit describes the state of a key after a request.
+type RateLimit struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until a denied request would be allowed.
+	RetryAfter time.Duration
+}
+
+// NewTokenBucketLimiter returns a RateLimiter allowing bursts of up to limit
+// requests per key, with the capacity refilling continuously at limit per
+// period. If key is nil, requests are keyed by IPKey(). If clock is nil,
+// RealClock is used.
+func NewTokenBucketLimiter(limit int, period time.Duration, key KeyFunc, clock timeutil.Clock) *RateLimiter {
+	return newRateLimiter(limit, period, false, key, clock)
+}
+
+// NewSlidingWindowLimiter returns a RateLimiter allowing up to limit requests
+// per key within any window of a given length. The count within the sliding
+// window is approximated by weighting the previous fixed window's count. If
+// key is nil, requests are keyed by IPKey(). If clock is nil, RealClock is
+// used.
+func NewSlidingWindowLimiter(limit int, window time.Duration, key KeyFunc, clock timeutil.Clock) *RateLimiter {
+	return newRateLimiter(limit, window, true, key, clock)
+}
+
+func newRateLimiter(limit int, period time.Duration, sliding bool, key KeyFunc, clock timeutil.Clock) *RateLimiter {
+	if limit <= 0 || period <= 0 {
+		panic("non-positive rate limit")
+	}
+	if key == nil {
+		key = IPKey()
+	}
+	if clock == nil {
+		clock = timeutil.RealClock{}
+	}
+	return &RateLimiter{
+		limit:     limit,
+		period:    period,
+		sliding:   sliding,
+		key:       key,
+		clock:     clock,
+		states:    make(map[string]*rateState),
+		lastSweep: clock.Now(),
+	}
+}
+
+// Allow records a request for a key and reports whether it is allowed.
+func (l *RateLimiter) Allow(key string) RateLimit {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.period {
+		l.sweep(now)
+	}
+	s, ok := l.states[key]
+	if !ok {
+		s = &rateState{tokens: float64(l.limit), last: now, start: now.Truncate(l.period)}
+		l.states[key] = s
+	}
+
+	if l.sliding {
+		return l.allowSlidingWindow(s, now)
+	}
+	return l.allowTokenBucket(s, now)
+}
+
+func (l *RateLimiter) allowTokenBucket(s *rateState, now time.Time) RateLimit {
+	rate := float64(l.limit) / float64(l.period) // tokens per nanosecond
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = math.Min(float64(l.limit), s.tokens+float64(elapsed)*rate)
+		s.last = now
+	}
+
+	res := RateLimit{Limit: l.limit}
+	if s.tokens >= 1 {
+		s.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - s.tokens) / rate)
+	}
+	res.Remaining = int(s.tokens)
+	res.Reset = time.Duration((float64(l.limit) - s.tokens) / rate)
+	return res
+}
+
+func (l *RateLimiter) allowSlidingWindow(s *rateState, now time.Time) RateLimit {
+	start := now.Truncate(l.period)
+	if !start.Equal(s.start) {
+		if start.Equal(s.start.Add(l.period)) {
+			s.prev = s.curr
+		} else {
+			s.prev = 0
+		}
+		s.curr = 0
+		s.start = start
+	}
+
+	elapsed := float64(now.Sub(start)) / float64(l.period)
+	count := float64(s.prev)*(1-elapsed) + float64(s.curr)
+
+	res := RateLimit{Limit: l.limit}
+	if count+1 <= float64(l.limit) {
+		s.curr++
+		count++
+		res.Allowed = true
+	} else {
+		// Find the point in time the weighted count drops enough.
+		need := float64(l.limit - 1)
+		var at float64 // in periods since start
+		if float64(s.curr) <= need && s.prev > 0 {
+			at = 1 - (need-float64(s.curr))/float64(s.prev)
+		} else {
+			at = 1
+			if s.curr > 0 {
+				at += math.Max(0, 1-need/float64(s.curr))
+			}
+		}
+		res.RetryAfter = time.Duration(at*float64(l.period)) - now.Sub(start)
+	}
+	res.Remaining = int(math.Max(0, float64(l.limit)-count))
+	if s.curr > 0 {
+		res.Reset = start.Add(2 * l.period).Sub(now)
+	} else {
+		res.Reset = start.Add(l.period).Sub(now)
+	}
+	return res
+}
+
+// sweep evicts the state of keys which have fully recovered. It must be
+// called with l.mu held.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, s := range l.states {
+		if l.sliding && now.Sub(s.start) >= 2*l.period || !l.sliding && now.Sub(s.last) >= l.period {
+			delete(l.states, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Handler wraps an HTTP handler to limit the rate of requests.
+func (l *RateLimiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.key(r)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		res := l.Allow(key)
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		if res.Allowed {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		code := http.StatusTooManyRequests
+		header.Set("Retry-After", ceilSeconds(res.RetryAfter))
+		json.WriteResponse(w, code, json.APIResponse{Code: code, Message: http.StatusText(code)})
+	})
+}
+
+// ceilSeconds formats a duration as whole seconds, rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
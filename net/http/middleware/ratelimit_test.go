@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	timeutil "github.com/djui/pkg/time"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	l := NewTokenBucketLimiter(3, 3*time.Second, HeaderKey("X-API-Key"), clock)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 2; i >= 0; i-- {
+		w := request("a")
+		if w.Code != 200 || w.Header().Get("RateLimit-Remaining") != string(rune('0'+i)) {
+			t.Fatalf("unexpected response %d, remaining %s", w.Code, w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+
+	w := request("a")
+	if w.Code != 429 || w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Reset") != "3" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Content-Type") != "application/json" || !strings.Contains(w.Body.String(), `"code":429`) {
+		t.Fatalf("unexpected body %q", w.Body)
+	}
+	if request("b").Code != 200 || request("").Code != 200 {
+		t.Fatal("expected other keys to be allowed")
+	}
+
+	clock.Advance(time.Second)
+	if request("a").Code != 200 || request("a").Code != 429 {
+		t.Fatal("expected one token to be refilled")
+	}
+
+	clock.Advance(time.Hour)
+	request("c")
+	if len(l.states) != 1 {
+		t.Fatalf("expected idle keys to be evicted, got %d", len(l.states))
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	l := NewSlidingWindowLimiter(4, time.Minute, nil, clock)
+
+	for i := 0; i < 4; i++ {
+		if !l.Allow("a").Allowed {
+			t.Fatalf("request %d denied", i)
+		}
+	}
+	res := l.Allow("a")
+	if res.Allowed || res.RetryAfter != time.Minute+15*time.Second {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	// Halfway into the next window, the previous window counts half.
+	clock.Advance(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if !l.Allow("a").Allowed {
+			t.Fatalf("request %d denied", i)
+		}
+	}
+	if res := l.Allow("a"); res.Allowed || res.Remaining != 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestIPKey(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		remoteAddr, forwardedFor string
+		trusted                  bool
+		expected                 string
+	}{
+		{"192.0.2.1:1234", "", false, "192.0.2.1"},
+		{"192.0.2.1:1234", "198.51.100.1", false, "192.0.2.1"},
+		{"10.0.0.1:1234", "198.51.100.1", false, "10.0.0.1"},
+		{"10.0.0.1:1234", "198.51.100.1", true, "198.51.100.1"},
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.1, 10.0.0.2", true, "198.51.100.1"},
+		{"10.0.0.1:1234", "10.0.0.3, 10.0.0.2", true, "10.0.0.3"},
+		{"[2001:db8::1]:1234", "", true, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		key := IPKey()
+		if tt.trusted {
+			key = IPKey(proxies)
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		if actual := key(r); actual != tt.expected {
+			t.Fatalf("%s %s: %q != %q", tt.remoteAddr, tt.forwardedFor, tt.expected, actual)
+		}
+	}
+}
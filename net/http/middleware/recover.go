@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	stdlog "log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/djui/pkg/errors"
+	"github.com/djui/pkg/log"
+	"github.com/djui/pkg/net/http/json"
+)
+
+// Recover returns a wrapper function (often known as middleware) which can be
+// used to wrap an HTTP handler to recover from panics. A panic is converted to
+// an error with the stack trace of the panic, which is logged with %+v to
+// logger, or to standard error if logger is nil.
+//
+// If the handler did not write the response header yet, it is answered with
+// 500 Internal Server Error as a JSON APIResponse, or as plain text if the
+// client prefers it via the Accept header. Otherwise the response is aborted
+// by panicking with http.ErrAbortHandler, which makes net/http close the
+// connection without logging.
+func Recover(logger log.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = stdlog.New(os.Stderr, "", stdlog.LstdFlags)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusResponseWriter{responseWriter: responseWriter{w}}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				// Deferred functions run on top of the panicking stack, so
+				// the error's stack trace includes the origin of the panic.
+				err := errors.Errorf("panic: %v", v)
+				if id := RequestIDFromContext(r.Context()); id != "" {
+					logger.Printf("%s %s (request %s): %+v", r.Method, r.URL.Path, id, err)
+				} else {
+					logger.Printf("%s %s: %+v", r.Method, r.URL.Path, err)
+				}
+
+				if sw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				// Drop headers describing the response the handler intended,
+				// and keep caches from storing the error.
+				header := w.Header()
+				for _, name := range []string{"Content-Length", contentEncoding, "Cache-Control",
+					"Expires", "ETag", "Last-Modified", "Set-Cookie"} {
+					header.Del(name)
+				}
+				header.Set("Cache-Control", "no-store")
+				code := http.StatusInternalServerError
+				if prefersPlainText(r) {
+					http.Error(w, http.StatusText(code), code)
+					return
+				}
+				json.WriteResponse(w, code, json.APIResponse{Code: code, Message: http.StatusText(code)})
+			}()
+			h.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// prefersPlainText reports whether the Accept header of a request prefers
+// text/plain over application/json.
+func prefersPlainText(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+	ranges := parseMediaRanges(accept)
+	quality := func(candidates ...string) float64 {
+		for _, c := range candidates {
+			if q, ok := ranges[c]; ok {
+				return q
+			}
+		}
+		return 0
+	}
+	return quality("text/plain", "text/*", "*/*") > quality("application/json", "application/*", "*/*")
+}
+
+// parseMediaRanges parses an Accept header into the quality values of its
+// media ranges. Invalid media ranges are ignored.
+//
+// See: https://tools.ietf.org/html/rfc7231#section-5.3.2
+func parseMediaRanges(s string) map[string]float64 {
+	ranges := make(map[string]float64)
+	for _, part := range strings.Split(s, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges[mediaType] = q
+	}
+	return ranges
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	h := Recover(stdlog.New(&buf, "", 0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		if r.URL.Path == "/late" {
+			io.WriteString(w, "partial")
+		}
+		panic("boom")
+	}))
+
+	tests := []struct {
+		accept, contentType string
+	}{
+		{"", "application/json"},
+		{"application/json, text/plain;q=0.5", "application/json"},
+		{"text/plain", "text/plain; charset=utf-8"},
+		{"text/*, application/json;q=0.1", "text/plain; charset=utf-8"},
+		{"text/plain; charset=utf-8; q=0.9, application/json; q=0.8", "text/plain; charset=utf-8"},
+		{"text/plain;q=x, application/json;q=0.1", "application/json"},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != 500 || w.Header().Get("Content-Type") != tt.contentType {
+			t.Fatalf("%q: unexpected response %d %q", tt.accept, w.Code, w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("Cache-Control") != "no-store" || w.Header().Get("ETag") != "" || w.Header().Get("Set-Cookie") != "" {
+			t.Fatalf("%q: unexpected headers %v", tt.accept, w.Header())
+		}
+		if !strings.Contains(buf.String(), "panic: boom") || !strings.Contains(buf.String(), "TestRecover") {
+			t.Fatalf("expected stack trace in log, got %q", buf.String())
+		}
+	}
+
+	var resp struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != 500 {
+		t.Fatalf("unexpected body %q: %v", w.Body, err)
+	}
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("expected http.ErrAbortHandler, got %v", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/late", nil))
+}
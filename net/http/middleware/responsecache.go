@@ -0,0 +1,492 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	stdlog "log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djui/pkg/errors"
+	"github.com/djui/pkg/log"
+	timeutil "github.com/djui/pkg/time"
+)
+
+// ResponseCache is an in-memory cache of full HTTP responses, evicting the
+// least recently used responses to stay within a memory budget.
+//
+// Responses to GET and HEAD requests are cached as long as the handler marks
+// them fresh via the Cache-Control max-age or s-maxage directives or an
+// Expires header. Responses marked private or no-store, setting cookies,
+// varying on "*" or with a status code not cacheable by default are not
+// cached, nor are responses to requests carrying credentials. Cached
+// responses are keyed by method, host, URL and the request headers listed in
+// their Vary header. Stale responses are served for the time given by the
+// stale-while-revalidate directive, while being refreshed in the background.
+// Concurrent requests for the same missing response are coalesced into one
+// handler call.
+type ResponseCache struct {
+	// Logger receives panics of handlers refreshing stale responses in the
+	// background. If nil, they are logged to standard error.
+	Logger log.Logger
+
+	clock    timeutil.Clock
+	maxBytes int64
+
+	mu       sync.Mutex
+	size     int64
+	lru      *list.List               // of *cachedResponse, most recent first
+	entries  map[string]*list.Element // by full key
+	vary     map[string][]string      // Vary header names by base key
+	inflight map[string]*cacheCall    // by full key
+}
+
+type cachedResponse struct {
+	key, baseKey string
+	varyNames    []string
+	status       int
+	header       http.Header
+	body         []byte
+	stored       time.Time
+	expires      time.Time
+	staleUntil   time.Time
+	size         int64
+}
+
+// cacheCall is a handler call shared by concurrent requests.
+type cacheCall struct {
+	done chan struct{}
+	res  *cachedResponse // nil if the response was not cacheable
+}
+
+// entryOverhead approximates the memory used by a cache entry besides its
+// key, header and body.
+const entryOverhead = 256
+
+// NewResponseCache returns a cache using at most maxBytes of memory for
+// responses, measuring time with a given clock. If clock is nil, RealClock is
+// used.
+func NewResponseCache(maxBytes int64, clock timeutil.Clock) *ResponseCache {
+	if clock == nil {
+		clock = timeutil.RealClock{}
+	}
+	return &ResponseCache{
+		clock:    clock,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		vary:     make(map[string][]string),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// CacheKey returns the key responses to a request are cached by, apart from
+// varying request headers, e.g. "GET example.com/users?page=2".
+func CacheKey(r *http.Request) string {
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+// Purge removes all cached responses for a key as returned by CacheKey, and
+// reports whether there were any.
+func (c *ResponseCache) Purge(key string) bool {
+	return c.purge(func(baseKey string) bool { return baseKey == key }) > 0
+}
+
+// PurgePrefix removes all cached responses whose key starts with a prefix,
+// e.g. "GET example.com/users", and returns their number.
+func (c *ResponseCache) PurgePrefix(prefix string) int {
+	return c.purge(func(baseKey string) bool { return strings.HasPrefix(baseKey, prefix) })
+}
+
+func (c *ResponseCache) purge(match func(baseKey string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, e := range c.entries {
+		if res := e.Value.(*cachedResponse); match(res.baseKey) {
+			c.remove(e)
+			n++
+		}
+	}
+	for baseKey := range c.vary {
+		if match(baseKey) {
+			delete(c.vary, baseKey)
+		}
+	}
+	return n
+}
+
+// Size returns the memory used by cached responses in bytes.
+func (c *ResponseCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Handler wraps an HTTP handler to cache its responses.
+func (c *ResponseCache) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		baseKey := CacheKey(r)
+		now := c.clock.Now()
+
+		c.mu.Lock()
+		key := c.fullKey(baseKey, r)
+		if e, ok := c.entries[key]; ok {
+			res := e.Value.(*cachedResponse)
+			switch {
+			case now.Before(res.expires):
+				c.lru.MoveToFront(e)
+				c.mu.Unlock()
+				res.serve(w, now, "HIT")
+				return
+			case now.Before(res.staleUntil):
+				c.lru.MoveToFront(e)
+				if _, ok := c.inflight[key]; !ok {
+					call := &cacheCall{done: make(chan struct{})}
+					c.inflight[key] = call
+					go c.refresh(h, r.Clone(context.Background()), baseKey, key, call)
+				}
+				c.mu.Unlock()
+				res.serve(w, now, "STALE")
+				return
+			default:
+				c.remove(e)
+			}
+		}
+
+		if call, ok := c.inflight[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-r.Context().Done():
+				return
+			}
+			// Requests are coalesced before the response's Vary header is
+			// known, so it might vary on headers this request differs in.
+			if res := call.res; res != nil && varyKey(baseKey, res.varyNames, r) == res.key {
+				res.serve(w, c.clock.Now(), "HIT")
+				return
+			}
+			// The response is not shareable, so produce one of our own.
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		call := &cacheCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		c.mu.Unlock()
+
+		w.Header().Set("X-Cache", "MISS")
+		rec := &cacheRecorder{responseWriter: responseWriter{w}, maxBody: c.maxBytes}
+		// Release waiting requests even if the handler panics.
+		defer c.complete(rec, r, baseKey, key, call)
+		h.ServeHTTP(rec, r)
+		rec.finish()
+	})
+}
+
+// refresh calls the handler to replace a stale response.
+func (c *ResponseCache) refresh(h http.Handler, r *http.Request, baseKey, key string, call *cacheCall) {
+	rec := &cacheRecorder{responseWriter: responseWriter{&discardResponseWriter{header: make(http.Header)}}, maxBody: c.maxBytes}
+	defer c.complete(rec, r, baseKey, key, call)
+	defer func() {
+		// Like for requests served by net/http, a panicking handler must not
+		// crash the server; the stale response is kept until it expires.
+		if v := recover(); v != nil {
+			logger := c.Logger
+			if logger == nil {
+				logger = stdlog.New(os.Stderr, "", stdlog.LstdFlags)
+			}
+			// Deferred functions run on top of the panicking stack, so the
+			// error's stack trace includes the origin of the panic.
+			err := errors.Errorf("panic: %v", v)
+			logger.Printf("%s %s (refresh): %+v", r.Method, r.URL.Path, err)
+		}
+	}()
+	h.ServeHTTP(rec, r)
+	rec.finish()
+}
+
+// complete stores a recorded response if cacheable and releases the requests
+// waiting for it.
+func (c *ResponseCache) complete(rec *cacheRecorder, r *http.Request, baseKey, key string, call *cacheCall) {
+	res := c.newCachedResponse(rec, baseKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, key)
+	if res != nil {
+		res.varyNames = headerValues(res.header, "Vary")
+		c.vary[baseKey] = res.varyNames
+		res.key = varyKey(baseKey, res.varyNames, r)
+		if e, ok := c.entries[res.key]; ok {
+			c.remove(e)
+		}
+		c.add(res)
+	}
+	call.res = res
+	close(call.done)
+}
+
+// newCachedResponse returns the cache entry for a recorded response, or nil
+// if it is not cacheable.
+func (c *ResponseCache) newCachedResponse(rec *cacheRecorder, baseKey string) *cachedResponse {
+	if !rec.complete || rec.overflow || rec.header == nil {
+		return nil
+	}
+	if rec.status == http.StatusPartialContent || !containsInt(defaultCacheableStatuses, rec.status) {
+		return nil
+	}
+
+	header := rec.header
+	if _, ok := header["Set-Cookie"]; ok {
+		return nil
+	}
+	for _, v := range headerValues(header, "Vary") {
+		if v == "*" {
+			return nil
+		}
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+	if _, ok := directives["private"]; ok {
+		return nil
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return nil
+	}
+
+	now := c.clock.Now()
+	var expires time.Time
+	if v, ok := directives["s-maxage"]; ok {
+		expires = now.Add(parseDeltaSeconds(v))
+	} else if v, ok := directives["max-age"]; ok {
+		expires = now.Add(parseDeltaSeconds(v))
+	} else if v := header.Get("Expires"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return nil
+		}
+		expires = t
+	}
+	if !expires.After(now) {
+		return nil
+	}
+
+	staleUntil := expires
+	if v, ok := directives["stale-while-revalidate"]; ok {
+		staleUntil = expires.Add(parseDeltaSeconds(v))
+	}
+
+	res := &cachedResponse{
+		baseKey:    baseKey,
+		status:     rec.status,
+		header:     header,
+		body:       rec.body.Bytes(),
+		stored:     now,
+		expires:    expires,
+		staleUntil: staleUntil,
+	}
+	res.size = entryOverhead + int64(2*len(baseKey)+len(res.body))
+	for k, vs := range header {
+		for _, v := range vs {
+			res.size += int64(len(k) + len(v))
+		}
+	}
+	if res.size > c.maxBytes {
+		return nil
+	}
+	return res
+}
+
+// fullKey returns the key of the response to a request, including the
+// request headers the response varies on. It must be called with c.mu held.
+func (c *ResponseCache) fullKey(baseKey string, r *http.Request) string {
+	return varyKey(baseKey, c.vary[baseKey], r)
+}
+
+func varyKey(baseKey string, names []string, r *http.Request) string {
+	if len(names) == 0 {
+		return baseKey
+	}
+	var b strings.Builder
+	b.WriteString(baseKey)
+	for _, name := range names {
+		b.WriteByte(0)
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// add adds a response, evicting the least recently used responses to stay
+// within the memory budget. It must be called with c.mu held.
+func (c *ResponseCache) add(res *cachedResponse) {
+	for c.size+res.size > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+	c.entries[res.key] = c.lru.PushFront(res)
+	c.size += res.size
+}
+
+// remove removes a response. It must be called with c.mu held.
+func (c *ResponseCache) remove(e *list.Element) {
+	res := c.lru.Remove(e).(*cachedResponse)
+	delete(c.entries, res.key)
+	c.size -= res.size
+}
+
+// serve writes a cached response, with the X-Cache header set to status.
+func (res *cachedResponse) serve(w http.ResponseWriter, now time.Time, status string) {
+	h := w.Header()
+	for k, vs := range res.header {
+		h[k] = append([]string(nil), vs...)
+	}
+	h.Set("Age", strconv.FormatInt(int64(now.Sub(res.stored)/time.Second), 10))
+	h.Set("X-Cache", status)
+	w.WriteHeader(res.status)
+	w.Write(res.body)
+}
+
+// cacheRecorder passes a response on while recording it.
+type cacheRecorder struct {
+	responseWriter
+	maxBody int64
+
+	status   int
+	header   http.Header // snapshot taken when the header was written
+	body     bytes.Buffer
+	overflow bool
+	hijacked bool
+	complete bool
+}
+
+func (w *cacheRecorder) WriteHeader(code int) {
+	if w.header == nil && code >= 200 {
+		w.status = code
+		w.header = w.Header().Clone()
+		w.header.Del("X-Cache")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheRecorder) Write(b []byte) (int, error) {
+	if w.header == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		w.overflow = true
+	} else if !w.overflow {
+		if int64(w.body.Len()+n) > w.maxBody {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b[:n])
+		}
+	}
+	return n, err
+}
+
+// finish marks the recorded response complete once the handler returned.
+func (w *cacheRecorder) finish() {
+	if w.header == nil && !w.hijacked {
+		// Record the implicit 200 OK of an empty response.
+		w.WriteHeader(http.StatusOK)
+	}
+	w.complete = true
+}
+
+// Flush flushes the underlying http.ResponseWriter if it is an http.Flusher.
+func (w *cacheRecorder) Flush() {
+	if w.header == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.responseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does. Hijacked responses are not cached.
+func (w *cacheRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.responseWriter.Hijack()
+	if err == nil {
+		w.hijacked = true
+		w.overflow = true
+	}
+	return conn, rw, err
+}
+
+// discardResponseWriter is an http.ResponseWriter discarding everything.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+// parseCacheControl parses a Cache-Control header value into a map of
+// lower-case directives to their unquoted arguments.
+func parseCacheControl(s string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, value = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return directives
+}
+
+// parseDeltaSeconds parses a number of seconds, returning zero for invalid
+// values.
+func parseDeltaSeconds(s string) time.Duration {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+// headerValues returns the comma separated values of a header, canonicalized
+// for use as header names.
+func headerValues(h http.Header, name string) []string {
+	var values []string
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, http.CanonicalHeaderKey(part))
+			}
+		}
+	}
+	return values
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
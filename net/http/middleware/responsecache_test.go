@@ -0,0 +1,294 @@
+package middleware
+
+import (
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	timeutil "github.com/djui/pkg/time"
+)
+
+func get(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestResponseCache(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	c := NewResponseCache(1<<20, clock)
+	var calls int32
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/cookie":
+			w.Header().Set("Cache-Control", "max-age=60")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		case "/expires":
+			w.Header().Set("Expires", clock.Now().Add(time.Minute).Format(http.TimeFormat))
+		default:
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		}
+		io.WriteString(w, "hello "+r.URL.Path)
+	}))
+
+	tests := []struct {
+		path    string
+		advance time.Duration
+		cache   string
+		calls   int32
+	}{
+		{"/a", 0, "MISS", 1},
+		{"/a", 30 * time.Second, "HIT", 1},
+		{"/a", 31 * time.Second, "MISS", 2},
+		{"/a?page=2", 0, "MISS", 3},
+		{"/expires", 0, "MISS", 4},
+		{"/expires", 0, "HIT", 4},
+		{"/private", 0, "MISS", 5},
+		{"/private", 0, "MISS", 6},
+		{"/cookie", 0, "MISS", 7},
+		{"/cookie", 0, "MISS", 8},
+	}
+
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		w := get(h, tt.path)
+		if w.Header().Get("X-Cache") != tt.cache || atomic.LoadInt32(&calls) != tt.calls {
+			t.Fatalf("%s: expected %s after %d calls, got %s after %d", tt.path,
+				tt.cache, tt.calls, w.Header().Get("X-Cache"), calls)
+		}
+		if w.Body.String() != "hello "+strings.SplitN(tt.path, "?", 2)[0] {
+			t.Fatalf("%s: unexpected body %q", tt.path, w.Body)
+		}
+	}
+
+	if w := get(h, "/a"); w.Header().Get("Age") != "0" {
+		t.Fatalf("unexpected Age %q", w.Header().Get("Age"))
+	}
+	if !c.Purge("GET example.com/a") || c.Purge("GET example.com/a") {
+		t.Fatal("unexpected purge result")
+	}
+	if n := c.PurgePrefix("GET example.com/"); n != 2 {
+		t.Fatalf("2 != %d", n)
+	}
+	if c.Size() != 0 {
+		t.Fatalf("unexpected size %d", c.Size())
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	c := NewResponseCache(1<<20, timeutil.NewFakeClock(time.Unix(0, 0)))
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	}))
+
+	for _, lang := range []string{"en", "de", "en", "de"} {
+		if w := get(h, "/", "Accept-Language", lang); w.Body.String() != lang {
+			t.Fatalf("%q != %q", lang, w.Body)
+		}
+	}
+	if w := get(h, "/", "Accept-Language", "de"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("expected hit")
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	c := NewResponseCache(3*(entryOverhead+200), timeutil.NewFakeClock(time.Unix(0, 0)))
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, strings.Repeat("x", 100))
+	}))
+
+	get(h, "/a")
+	get(h, "/b")
+	get(h, "/c")
+	get(h, "/a") // mark /a as recently used
+	get(h, "/d") // evicts /b
+
+	for path, cache := range map[string]string{"/a": "HIT", "/b": "MISS"} {
+		if w := get(h, path); w.Header().Get("X-Cache") != cache {
+			t.Fatalf("%s: expected %s", path, cache)
+		}
+	}
+	if c.Size() > c.maxBytes {
+		t.Fatalf("size %d exceeds budget", c.Size())
+	}
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	c := NewResponseCache(1<<20, clock)
+	var version int32
+	refreshed := make(chan struct{}, 1)
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		io.WriteString(w, string(rune('0'+atomic.AddInt32(&version, 1))))
+		select {
+		case refreshed <- struct{}{}:
+		default:
+		}
+	}))
+
+	get(h, "/")
+	<-refreshed
+	clock.Advance(20 * time.Second)
+
+	w := get(h, "/")
+	if w.Header().Get("X-Cache") != "STALE" || w.Body.String() != "1" {
+		t.Fatalf("unexpected response %s %q", w.Header().Get("X-Cache"), w.Body)
+	}
+	<-refreshed
+	for i := 0; ; i++ {
+		if w := get(h, "/"); w.Header().Get("X-Cache") == "HIT" && w.Body.String() == "2" {
+			break
+		}
+		if i == 100 {
+			t.Fatal("response not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if w := get(h, "/"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("expected miss after stale period")
+	}
+}
+
+func TestResponseCacheCoalescing(t *testing.T) {
+	c := NewResponseCache(1<<20, nil)
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "hello")
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		get(h, "/")
+	}()
+	<-started
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := get(h, "/"); w.Body.String() != "hello" {
+				t.Errorf("unexpected body %q", w.Body)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("1 != %d", calls)
+	}
+}
+
+func TestResponseCacheCoalescingVary(t *testing.T) {
+	c := NewResponseCache(1<<20, nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := r.Header.Get("Accept-Language")
+		if lang == "de" {
+			close(started)
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, lang)
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if w := get(h, "/", "Accept-Language", "de"); w.Body.String() != "de" {
+			t.Errorf("de != %q", w.Body)
+		}
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		if w := get(h, "/", "Accept-Language", "fr"); w.Body.String() != "fr" {
+			t.Errorf("fr != %q", w.Body)
+		}
+	}()
+	// Let the second request wait for the first one.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestResponseCacheRefreshPanic(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	c := NewResponseCache(1<<20, clock)
+	logged := make(chan string, 1)
+	c.Logger = stdlog.New(writerFunc(func(b []byte) (int, error) {
+		logged <- string(b)
+		return len(b), nil
+	}), "", 0)
+	var calls int32
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			panic("boom")
+		}
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		io.WriteString(w, "hello")
+	}))
+
+	get(h, "/")
+	clock.Advance(20 * time.Second)
+	if w := get(h, "/"); w.Header().Get("X-Cache") != "STALE" {
+		t.Fatal("expected stale response")
+	}
+	if line := <-logged; !strings.Contains(line, "panic: boom") {
+		t.Fatalf("unexpected log %q", line)
+	}
+}
+
+func TestResponseCacheHijack(t *testing.T) {
+	c := NewResponseCache(1<<20, timeutil.NewFakeClock(time.Unix(0, 0)))
+	calls := 0
+	hijack := hijackHandler(t)
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		hijack.ServeHTTP(w, r)
+	}))
+
+	for i := 1; i <= 2; i++ {
+		w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if !w.hijacked || w.lateHeaders != 0 || calls != i {
+			t.Fatalf("unexpected hijack %t after %d headers and %d calls", w.hijacked, w.lateHeaders, calls)
+		}
+	}
+}
+
+type writerFunc func(b []byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// errHijackNotSupported is returned by Hijack if the underlying
+// http.ResponseWriter is no http.Hijacker.
+var errHijackNotSupported = errors.New("response writer does not support hijacking")
+
+// responseWriter wraps an http.ResponseWriter, forwarding the optional
+// interfaces implemented by the response writers of package http. Wrappers
+// embed it and override the methods they need to observe.
+type responseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush flushes the underlying http.ResponseWriter if it is an http.Flusher.
+func (w responseWriter) Flush() {
+	if fw, ok := w.ResponseWriter.(http.Flusher); ok {
+		fw.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface if the underlying
+// http.ResponseWriter does.
+func (w responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	return hj.Hijack()
+}
+
+// Push implements the http.Pusher interface if the underlying
+// http.ResponseWriter does.
+func (w responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// readFrom passes r on to the underlying http.ResponseWriter if it is an
+// io.ReaderFrom, allowing it to use sendfile, and copies it to dst otherwise.
+// Wrappers pass themselves as dst to implement io.ReaderFrom.
+func (w responseWriter) readFrom(dst io.Writer, r io.Reader) (int64, error) {
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{dst}, r)
+}
+
+// writerOnly hides all methods but Write, so io.Copy does not recurse into
+// ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,298 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSPNonce is a placeholder source in a CSP, which is replaced by a nonce
+// source with a value generated per request, e.g. 'nonce-cmFuZG9t'. Use
+// CSPNonceFromContext to retrieve the value for script and style tags.
+const CSPNonce = "'nonce'"
+
+// CSP builds a Content-Security-Policy. Directives are emitted in the order
+// they were first set.
+//
+// See: https://www.w3.org/TR/CSP3/
+type CSP struct {
+	directives []cspDirective
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// NewCSP returns an empty CSP.
+func NewCSP() *CSP {
+	return &CSP{}
+}
+
+// Set sets the sources of a directive, replacing previous ones. Directives
+// without sources, such as upgrade-insecure-requests, are set without sources.
+func (p *CSP) Set(directive string, sources ...string) *CSP {
+	directive = strings.ToLower(directive)
+	for i := range p.directives {
+		if p.directives[i].name == directive {
+			p.directives[i].sources = append([]string(nil), sources...)
+			return p
+		}
+	}
+	p.directives = append(p.directives, cspDirective{directive, append([]string(nil), sources...)})
+	return p
+}
+
+// Add adds sources to a directive.
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	return p.Set(directive, append(p.Get(directive), sources...)...)
+}
+
+// Get returns the sources of a directive.
+func (p *CSP) Get(directive string) []string {
+	directive = strings.ToLower(directive)
+	for _, d := range p.directives {
+		if d.name == directive {
+			return append([]string(nil), d.sources...)
+		}
+	}
+	return nil
+}
+
+// Has reports whether a directive is set.
+func (p *CSP) Has(directive string) bool {
+	directive = strings.ToLower(directive)
+	for _, d := range p.directives {
+		if d.name == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// Del removes a directive.
+func (p *CSP) Del(directive string) *CSP {
+	directive = strings.ToLower(directive)
+	for i, d := range p.directives {
+		if d.name == directive {
+			p.directives = append(p.directives[:i:i], p.directives[i+1:]...)
+			break
+		}
+	}
+	return p
+}
+
+// Clone returns a copy of the CSP, e.g. to derive a policy for a route.
+func (p *CSP) Clone() *CSP {
+	c := &CSP{directives: make([]cspDirective, len(p.directives))}
+	for i, d := range p.directives {
+		c.directives[i] = cspDirective{d.name, append([]string(nil), d.sources...)}
+	}
+	return c
+}
+
+// String returns the value of the Content-Security-Policy header, with
+// CSPNonce placeholders left as is.
+func (p *CSP) String() string {
+	return p.render("")
+}
+
+// render returns the value of the Content-Security-Policy header, replacing
+// CSPNonce placeholders with a nonce source unless the nonce is empty.
+func (p *CSP) render(nonce string) string {
+	var b strings.Builder
+	for _, d := range p.directives {
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(d.name)
+		for _, s := range d.sources {
+			if s == CSPNonce && nonce != "" {
+				s = "'nonce-" + nonce + "'"
+			}
+			b.WriteByte(' ')
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+func (p *CSP) usesNonce() bool {
+	for _, d := range p.directives {
+		for _, s := range d.sources {
+			if s == CSPNonce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type cspNonceKey struct{}
+
+// CSPNonceFromContext returns the CSP nonce generated for a request, or an
+// empty string if its CSP uses none.
+func CSPNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// SecurityHeaders describes a set of security related response headers.
+// Zero values omit the respective header.
+type SecurityHeaders struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header.
+	// Browsers ignore the header on responses over plain HTTP.
+	//
+	// See: https://tools.ietf.org/html/rfc6797
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubDomains bool
+	// HSTSPreload requests inclusion in browsers' preload lists, which
+	// requires a max-age of at least a year and includeSubDomains.
+	//
+	// See: https://hstspreload.org
+	HSTSPreload bool
+
+	// NoSniff sets X-Content-Type-Options to nosniff, so browsers do not
+	// guess content types.
+	NoSniff bool
+	// ReferrerPolicy is the value of the Referrer-Policy header, e.g.
+	// "strict-origin-when-cross-origin".
+	ReferrerPolicy string
+	// FrameAncestors lists the sources allowed to embed responses in frames,
+	// e.g. "'none'". It sets the frame-ancestors directive of the CSP unless
+	// the CSP sets it, and the X-Frame-Options header for older browsers if
+	// it is either 'none' or 'self'.
+	FrameAncestors []string
+
+	CSP *CSP
+	// CSPReportOnly emits the CSP as Content-Security-Policy-Report-Only
+	// header, so violations are reported but not enforced.
+	CSPReportOnly bool
+}
+
+// DefaultSecurityHeaders returns a hardened set of security headers. Its CSP
+// restricts scripts to same origin and nonce sources, and forbids plugins,
+// base URL changes and framing.
+func DefaultSecurityHeaders() SecurityHeaders {
+	return SecurityHeaders{
+		HSTSMaxAge:            2 * 365 * 24 * time.Hour,
+		HSTSIncludeSubDomains: true,
+		HSTSPreload:           true,
+		NoSniff:               true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		FrameAncestors:        []string{"'none'"},
+		CSP: NewCSP().
+			Set("default-src", "'self'").
+			Set("script-src", "'self'", CSPNonce).
+			Set("object-src", "'none'").
+			Set("base-uri", "'self'"),
+	}
+}
+
+// apply sets the headers on a response, returning the CSP nonce, if any.
+func (s *SecurityHeaders) apply(header http.Header) string {
+	if s.HSTSMaxAge > 0 {
+		v := "max-age=" + strconv.FormatInt(int64(s.HSTSMaxAge/time.Second), 10)
+		if s.HSTSIncludeSubDomains {
+			v += "; includeSubDomains"
+		}
+		if s.HSTSPreload {
+			v += "; preload"
+		}
+		header.Set("Strict-Transport-Security", v)
+	}
+	if s.NoSniff {
+		header.Set("X-Content-Type-Options", "nosniff")
+	}
+	if s.ReferrerPolicy != "" {
+		header.Set("Referrer-Policy", s.ReferrerPolicy)
+	}
+	if len(s.FrameAncestors) == 1 {
+		switch s.FrameAncestors[0] {
+		case "'none'":
+			header.Set("X-Frame-Options", "DENY")
+		case "'self'":
+			header.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+	}
+
+	csp := s.CSP
+	if len(s.FrameAncestors) > 0 && (csp == nil || !csp.Has("frame-ancestors")) {
+		if csp == nil {
+			csp = NewCSP()
+		} else {
+			csp = csp.Clone()
+		}
+		csp.Set("frame-ancestors", s.FrameAncestors...)
+	}
+	if csp == nil || len(csp.directives) == 0 {
+		return ""
+	}
+
+	var nonce string
+	if csp.usesNonce() {
+		nonce = newCSPNonce()
+	}
+	name := "Content-Security-Policy"
+	if s.CSPReportOnly {
+		name += "-Report-Only"
+	}
+	header.Set(name, csp.render(nonce))
+	return nonce
+}
+
+func newCSPNonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// SecurityRoute overrides the security headers for matching requests.
+type SecurityRoute struct {
+	// Path is a glob matched against the URL path, as in CacheRule. An empty
+	// Path matches any path.
+	Path    string
+	Headers SecurityHeaders
+}
+
+// SecurityPolicy sets security headers according to the first matching route.
+// Headers are set before the handler is called, so handlers can override or
+// delete them.
+type SecurityPolicy struct {
+	Routes []SecurityRoute
+	// Default holds the headers for requests matched by no route.
+	Default SecurityHeaders
+}
+
+// Secure wraps an HTTP handler to set DefaultSecurityHeaders on its
+// responses.
+func Secure(h http.Handler) http.Handler {
+	p := &SecurityPolicy{Default: DefaultSecurityHeaders()}
+	return p.Handler(h)
+}
+
+// Handler wraps an HTTP handler to set security headers on its responses. If
+// the CSP uses a nonce, it is available to the handler via
+// CSPNonceFromContext.
+func (p *SecurityPolicy) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := &p.Default
+		for i := range p.Routes {
+			if path := p.Routes[i].Path; path == "" || matchPathGlob(path, r.URL.Path) {
+				headers = &p.Routes[i].Headers
+				break
+			}
+		}
+
+		if nonce := headers.apply(w.Header()); nonce != "" {
+			r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSP(t *testing.T) {
+	p := NewCSP().
+		Set("default-src", "'self'").
+		Set("script-src", "'self'", CSPNonce).
+		Set("upgrade-insecure-requests").
+		Add("default-src", "https://cdn.example.com")
+
+	expected := "default-src 'self' https://cdn.example.com; script-src 'self' 'nonce'; upgrade-insecure-requests"
+	if actual := p.String(); actual != expected {
+		t.Fatalf("%q != %q", expected, actual)
+	}
+	if actual := p.render("abc"); !strings.Contains(actual, "script-src 'self' 'nonce-abc';") {
+		t.Fatalf("unexpected policy %q", actual)
+	}
+
+	c := p.Clone().Del("script-src")
+	if c.Has("script-src") || !p.Has("script-src") {
+		t.Fatal("expected clone to be independent")
+	}
+}
+
+func TestSecure(t *testing.T) {
+	var nonce string
+	h := Secure(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonceFromContext(r.Context())
+		io.WriteString(w, `<script nonce="`+nonce+`"></script>`)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=63072000; includeSubDomains; preload",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "DENY",
+		"Content-Security-Policy": "default-src 'self'; script-src 'self' 'nonce-" + nonce +
+			"'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+	}
+	for name, value := range expected {
+		if actual := w.Header().Get(name); actual != value {
+			t.Fatalf("%s: %q != %q", name, value, actual)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	previous := nonce
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if nonce == "" || nonce == previous {
+		t.Fatalf("expected a fresh nonce, got %q", nonce)
+	}
+}
+
+func TestSecurityPolicy(t *testing.T) {
+	embed := DefaultSecurityHeaders()
+	embed.FrameAncestors = []string{"https://partner.example.com"}
+	embed.CSP = NewCSP().Set("default-src", "'self'")
+	embed.CSPReportOnly = true
+
+	p := &SecurityPolicy{
+		Routes: []SecurityRoute{
+			{Path: "/embed/**", Headers: embed},
+			{Path: "/api/**", Headers: SecurityHeaders{NoSniff: true}},
+		},
+		Default: DefaultSecurityHeaders(),
+	}
+	h := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if CSPNonceFromContext(r.Context()) != "" && r.URL.Path != "/legacy" {
+			t.Errorf("%s: unexpected nonce", r.URL.Path)
+		}
+		if r.URL.Path == "/legacy" {
+			w.Header().Del("Content-Security-Policy")
+		}
+	}))
+
+	tests := []struct {
+		path, header, expected string
+	}{
+		{"/embed/video", "Content-Security-Policy-Report-Only", "default-src 'self'; frame-ancestors https://partner.example.com"},
+		{"/embed/video", "Content-Security-Policy", ""},
+		{"/embed/video", "X-Frame-Options", ""},
+		{"/api/users", "X-Content-Type-Options", "nosniff"},
+		{"/api/users", "Strict-Transport-Security", ""},
+		{"/legacy", "Content-Security-Policy", ""},
+		{"/legacy", "X-Frame-Options", "DENY"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if actual := w.Header().Get(tt.header); actual != tt.expected {
+			t.Fatalf("%s %s: %q != %q", tt.path, tt.header, tt.expected, actual)
+		}
+	}
+	if embed.CSP.Has("frame-ancestors") {
+		t.Fatal("expected the configured CSP to be unchanged")
+	}
+}
+
+func TestSecurityPolicyEmptyPath(t *testing.T) {
+	p := &SecurityPolicy{
+		Routes:  []SecurityRoute{{Headers: SecurityHeaders{ReferrerPolicy: "no-referrer"}}},
+		Default: DefaultSecurityHeaders(),
+	}
+	w := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest("GET", "/any", nil))
+	if actual := w.Header().Get("Referrer-Policy"); actual != "no-referrer" {
+		t.Fatalf("no-referrer != %q", actual)
+	}
+}
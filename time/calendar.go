@@ -0,0 +1,197 @@
+package time
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Calendar tells business days from weekends and holidays.
+type Calendar struct {
+	// Location defines where days start and end.
+	Location *time.Location
+	// Weekend lists the days of the week which are no business days.
+	Weekend []time.Weekday
+
+	holidays  map[date]string
+	recurring map[date]string // year is zero
+}
+
+// gregorianCycleDays is the number of days after which the Gregorian calendar
+// repeats its dates on the same weekdays.
+const gregorianCycleDays = 146097
+
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// NewCalendar returns a calendar without holidays in a given location, with
+// Saturday and Sunday as weekend. If loc is nil, time.Local is used.
+func NewCalendar(loc *time.Location) *Calendar {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Calendar{
+		Location:  loc,
+		Weekend:   []time.Weekday{time.Saturday, time.Sunday},
+		holidays:  make(map[date]string),
+		recurring: make(map[date]string),
+	}
+}
+
+// LoadCalendar loads holidays from a file as described by ParseCalendar.
+func LoadCalendar(path string, loc *time.Location) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseCalendar(f, loc)
+}
+
+// ParseCalendar reads holidays, one per line, each a date followed by an
+// optional name. Dates are formatted as "2006-01-02", or as "*-01-02" for
+// holidays recurring every year, separated from the name by any whitespace.
+// Empty lines and lines starting with '#' are ignored. For example:
+//
+//	# Public holidays
+//	*-01-01 New Year's Day
+//	2021-04-05 Easter Monday
+//	*-12-25 Christmas Day
+func ParseCalendar(r io.Reader, loc *time.Location) (*Calendar, error) {
+	c := NewCalendar(loc)
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		name := strings.Join(fields[1:], " ")
+
+		if strings.HasPrefix(fields[0], "*-") {
+			t, err := time.Parse("01-02", fields[0][2:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid date %q", n, fields[0])
+			}
+			c.AddRecurringHoliday(t.Month(), t.Day(), name)
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", fields[0], c.Location)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", n, fields[0])
+		}
+		c.AddHoliday(t, name)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// AddHoliday marks the day of t in the calendar's location as holiday.
+func (c *Calendar) AddHoliday(t time.Time, name string) {
+	c.holidays[c.date(t)] = name
+}
+
+// AddRecurringHoliday marks a day as holiday every year.
+func (c *Calendar) AddRecurringHoliday(month time.Month, day int, name string) {
+	c.recurring[date{0, month, day}] = name
+}
+
+// Holiday returns the name of the holiday on the day of t, and whether it is
+// one.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	d := c.date(t)
+	if name, ok := c.holidays[d]; ok {
+		return name, true
+	}
+	name, ok := c.recurring[date{0, d.month, d.day}]
+	return name, ok
+}
+
+// IsWeekend reports whether the day of t is on a weekend.
+func (c *Calendar) IsWeekend(t time.Time) bool {
+	wd := t.In(c.Location).Weekday()
+	for _, w := range c.Weekend {
+		if w == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBusinessDay reports whether the day of t is neither on a weekend nor a
+// holiday.
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if c.IsWeekend(t) {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// AddBusinessDays returns the time n business days after t, keeping the wall
+// clock time in the calendar's location. Negative n goes back in time. If t is
+// no business day, counting starts from it anyway, so adding one business day
+// to a Saturday yields the following Monday. It panics if the calendar has no
+// business days at all.
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	var weekend [7]bool
+	days := 0
+	for _, w := range c.Weekend {
+		if !weekend[w] {
+			weekend[w] = true
+			days++
+		}
+	}
+	if days == 7 {
+		panic("calendar has no business days")
+	}
+
+	// Weekdays and recurring holidays repeat with the Gregorian cycle, so a
+	// longer run of days off needs a dated holiday in each cycle.
+	maxGap := gregorianCycleDays * (len(c.holidays) + 1)
+
+	t = t.In(c.Location)
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for gap := 0; n > 0; {
+		t = t.AddDate(0, 0, step)
+		if c.IsBusinessDay(t) {
+			n--
+			gap = 0
+		} else if gap++; gap > maxGap {
+			panic("calendar has no business days")
+		}
+	}
+	return t
+}
+
+// BusinessDays returns the number of business days within a time range,
+// counting each day the range touches.
+func (c *Calendar) BusinessDays(r TimeRange) int {
+	n := 0
+	for _, day := range r.Days(c.Location) {
+		if c.IsBusinessDay(day) {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Calendar) date(t time.Time) date {
+	y, m, d := t.In(c.Location).Date()
+	return date{y, m, d}
+}
@@ -0,0 +1,93 @@
+package time
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHolidays = `
+# Public holidays
+*-12-25 Christmas Day
+*-12-26
+2021-12-31	New Year's  Eve
+`
+
+func TestParseCalendar(t *testing.T) {
+	c, err := ParseCalendar(strings.NewReader(testHolidays), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name, ok := c.Holiday(time.Date(2030, 12, 25, 9, 0, 0, 0, time.UTC)); !ok || name != "Christmas Day" {
+		t.Fatalf("unexpected holiday %q", name)
+	}
+	if _, ok := c.Holiday(time.Date(2022, 12, 31, 9, 0, 0, 0, time.UTC)); ok {
+		t.Fatal("unexpected holiday")
+	}
+	if name, _ := c.Holiday(time.Date(2021, 12, 31, 9, 0, 0, 0, time.UTC)); name != "New Year's Eve" {
+		t.Fatalf("unexpected holiday %q", name)
+	}
+
+	for _, s := range []string{"2021-13-01", "*-02-30 Foo", "tomorrow"} {
+		if _, err := ParseCalendar(strings.NewReader(s), time.UTC); err == nil {
+			t.Fatalf("%s: expected error", s)
+		}
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	c, err := ParseCalendar(strings.NewReader(testHolidays), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d int) time.Time { return time.Date(2021, 12, d, 9, 30, 0, 0, time.UTC) }
+	tests := []struct {
+		t        time.Time
+		n        int
+		expected time.Time
+	}{
+		{day(22), 1, day(23)},                  // Wednesday
+		{day(23), 1, day(24)},                  // Thursday
+		{day(24), 1, day(27)},                  // Friday, Christmas on Saturday
+		{day(23), 3, day(28)},                  // skip weekend
+		{day(30), 1, day(31).AddDate(0, 0, 3)}, // skip New Year's Eve
+		{day(27), -1, day(24)},
+		{day(25), 1, day(27)},
+		{day(22), 0, day(22)},
+	}
+
+	for _, tt := range tests {
+		actual := c.AddBusinessDays(tt.t, tt.n)
+		if !actual.Equal(tt.expected) {
+			t.Fatalf("%s %+d: %s != %s", tt.t, tt.n, tt.expected, actual)
+		}
+	}
+
+	if n := c.BusinessDays(TimeRange{day(20), day(27)}); n != 6 {
+		t.Fatalf("6 != %d", n)
+	}
+}
+
+func TestAddBusinessDaysNone(t *testing.T) {
+	c := NewCalendar(time.UTC)
+	c.Weekend = []time.Weekday{
+		time.Saturday, time.Saturday, time.Monday, time.Tuesday,
+		time.Wednesday, time.Thursday, time.Friday,
+	}
+	monday := time.Date(2021, 12, 20, 9, 30, 0, 0, time.UTC)
+	if actual := c.AddBusinessDays(monday, 1); actual.Weekday() != time.Sunday {
+		t.Fatalf("%s != %s", time.Sunday, actual.Weekday())
+	}
+
+	for d := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() == 2020; d = d.AddDate(0, 0, 1) {
+		c.AddRecurringHoliday(d.Month(), d.Day(), "")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	c.AddBusinessDays(monday, 1)
+}
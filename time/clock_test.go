@@ -0,0 +1,108 @@
+package time
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	timer := clock.NewTimer(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	if now := <-timer.C(); !now.Equal(time.Unix(1, 0)) {
+		t.Fatalf("%s != %s", time.Unix(1, 0), now)
+	}
+	if timer.Stop() {
+		t.Fatal("fired timer reported active")
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		if now := <-ticker.C(); !now.Equal(time.Unix(int64(i), 0)) {
+			t.Fatalf("%s != %s", time.Unix(int64(i), 0), now)
+		}
+	}
+}
+
+func TestBackoffTickerFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ticker := NewBackoffTicker(context.Background(), Backoff{
+		Strategy: Exponential,
+		Initial:  time.Second,
+		Max:      4 * time.Second,
+		Clock:    clock,
+	})
+	defer ticker.Stop()
+
+	start := clock.Now()
+	for _, expected := range []time.Duration{1, 3, 7, 11, 15} {
+		clock.BlockUntil(1)
+		clock.Set(start.Add(expected * time.Second))
+		if now := <-ticker.C; now.Sub(start) != expected*time.Second {
+			t.Fatalf("%s != %s", expected*time.Second, now.Sub(start))
+		}
+	}
+}
+
+func TestBackoffTickerResetAttempts(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	strategy := func(attempt int, _ time.Duration) time.Duration {
+		return time.Duration(attempt+1) * time.Second
+	}
+	ticker := NewBackoffTicker(context.Background(), Backoff{
+		AttemptStrategy: strategy,
+		Initial:         time.Second,
+		Clock:           clock,
+	})
+	defer ticker.Stop()
+
+	for round := 0; round < 2; round++ {
+		start := clock.Now()
+		for _, expected := range []time.Duration{1, 3, 6} {
+			clock.BlockUntil(1)
+			clock.Set(start.Add(expected * time.Second))
+			if now := <-ticker.C; now.Sub(start) != expected*time.Second {
+				t.Fatalf("round %d: %s != %s", round, expected*time.Second, now.Sub(start))
+			}
+		}
+		ticker.Reset()
+	}
+}
+
+func TestRetryFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	go func() {
+		for i := 0; i < 3; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(time.Hour)
+		}
+	}()
+
+	calls := 0
+	err := Retry(context.Background(), Backoff{Initial: time.Hour, Clock: clock}, 4, func() error {
+		calls++
+		return errors.New("fail")
+	})
+	if calls != 4 || err == nil {
+		t.Fatalf("unexpected result: %v after %d calls", err, calls)
+	}
+	if d := clock.Now().Sub(time.Unix(0, 0)); d != 3*time.Hour {
+		t.Fatalf("%s != %s", 3*time.Hour, d)
+	}
+}
@@ -0,0 +1,77 @@
+package time
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	// Monday
+	from := time.Date(2021, 3, 1, 10, 30, 15, 500, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 1, 10, 31, 0, 0, time.UTC)},
+		{"* * * * * *", time.Date(2021, 3, 1, 10, 30, 16, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2021, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 5", time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan ?", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 1h30m", time.Date(2021, 3, 1, 12, 0, 15, 0, time.UTC)},
+		{"CRON_TZ=Europe/Berlin 0 12 * * *", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if actual := s.Next(from); !actual.Equal(tt.expected) {
+			t.Fatalf("%s: %s != %s", tt.spec, tt.expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "@every x", "TZ=Nowhere/City * * * * *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestParseCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	fallBack := time.Date(2024, 11, 3, 0, 30, 0, 0, loc)
+	springForward := time.Date(2024, 3, 10, 0, 30, 0, 0, loc)
+
+	tests := []struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"0 3 * * *", fallBack, time.Date(2024, 11, 3, 3, 0, 0, 0, loc)},
+		{"@daily", fallBack.Add(-30*time.Minute + time.Second), time.Date(2024, 11, 4, 0, 0, 0, 0, loc)},
+		{"0 * * * *", fallBack.Add(time.Hour), fallBack.Add(90 * time.Minute)}, // 01:00 EDT to 01:00 EST
+		{"0 2 * * *", fallBack, fallBack.Add(150 * time.Minute)},               // 02:00 EST
+		{"0 3 * * *", springForward, time.Date(2024, 3, 10, 3, 0, 0, 0, loc)},
+		{"30 2 * * *", springForward, time.Date(2024, 3, 11, 2, 30, 0, 0, loc)}, // 02:30 does not exist
+		{"0 * * * *", springForward, springForward.Add(30 * time.Minute)},
+		{"0 * * * *", springForward.Add(30 * time.Minute), springForward.Add(90 * time.Minute)}, // 01:00 EST to 03:00 EDT
+	}
+
+	for _, tt := range tests {
+		if actual := MustParseCron(tt.spec).Next(tt.from); !actual.Equal(tt.expected) {
+			t.Fatalf("%s from %s: %s != %s", tt.spec, tt.from, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package time
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Day is a fixed 24 hour duration. It does not account for daylight
+	// saving time transitions.
+	Day = 24 * time.Hour
+	// Week is a fixed 7 day duration.
+	Week = 7 * Day
+)
+
+var unitMap = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond, // U+00B5 micro sign
+	"μs": time.Microsecond, // U+03BC Greek letter mu
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  Day,
+	"w":  Week,
+}
+
+// ParseDuration parses a duration string. In addition to the format accepted
+// by package time's ParseDuration, such as "300ms" or "-1.5h", it accepts the
+// units "d" for days and "w" for weeks, e.g. "3d12h" or "2w", and ISO 8601
+// durations such as "P1DT2H" or "PT0.5S". ISO 8601 years and months are
+// rejected as their length is ambiguous.
+func ParseDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+
+	var d time.Duration
+	var err error
+	if s != "" && (s[0] == 'P' || s[0] == 'p') {
+		d, err = parseISODuration(s[1:])
+	} else {
+		d, err = parseUnitDuration(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %s", orig, err)
+	}
+
+	if neg {
+		return -d, nil
+	}
+	return d, nil
+}
+
+func parseUnitDuration(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var d time.Duration
+	for s != "" {
+		var v time.Duration
+		var err error
+		v, s, err = parseComponent(s, func(s string) (time.Duration, string, bool) {
+			i := 0
+			for i < len(s) && s[i] != '.' && !isDigit(s[i]) {
+				i++
+			}
+			unit, ok := unitMap[s[:i]]
+			return unit, s[i:], ok
+		})
+		if err != nil {
+			return 0, err
+		}
+		if d += v; d < 0 {
+			return 0, fmt.Errorf("overflow")
+		}
+	}
+	return d, nil
+}
+
+func parseISODuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' || s[0] == 't' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("unexpected T")
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+
+		var v time.Duration
+		var err error
+		v, s, err = parseComponent(s, func(s string) (time.Duration, string, bool) {
+			if s == "" {
+				return 0, s, false
+			}
+			var unit time.Duration
+			switch c := strings.ToUpper(s[:1]); {
+			case !inTime && c == "W":
+				unit = Week
+			case !inTime && c == "D":
+				unit = Day
+			case inTime && c == "H":
+				unit = time.Hour
+			case inTime && c == "M":
+				unit = time.Minute
+			case inTime && c == "S":
+				unit = time.Second
+			default:
+				return 0, s, false
+			}
+			return unit, s[1:], true
+		})
+		if err != nil {
+			return 0, err
+		}
+		if d += v; d < 0 {
+			return 0, fmt.Errorf("overflow")
+		}
+	}
+	return d, nil
+}
+
+// parseComponent parses a decimal number with optional fraction followed by
+// a unit recognized by unit, and returns its duration.
+func parseComponent(s string, unit func(string) (time.Duration, string, bool)) (time.Duration, string, error) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	intPart := s[:i]
+	fracPart := ""
+	if i < len(s) && (s[i] == '.' || s[i] == ',') {
+		j := i + 1
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		fracPart = s[i+1 : j]
+		i = j
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, s, fmt.Errorf("expected number")
+	}
+
+	u, rest, ok := unit(s[i:])
+	if !ok {
+		return 0, s, fmt.Errorf("unknown unit in %q", s)
+	}
+
+	var v time.Duration
+	if intPart != "" {
+		n, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil || time.Duration(n) > maxDuration/u {
+			return 0, s, fmt.Errorf("overflow")
+		}
+		v = time.Duration(n) * u
+	}
+	scale := u
+	for k := 0; k < len(fracPart) && scale > 1; k++ {
+		scale /= 10
+		v += time.Duration(fracPart[k]-'0') * scale
+	}
+	if v < 0 {
+		return 0, s, fmt.Errorf("overflow")
+	}
+	return v, rest, nil
+}
+
+var humanUnits = []struct {
+	unit           time.Duration
+	one, many, abr string
+}{
+	{Day, "day", "days", "d"},
+	{time.Hour, "hour", "hours", "h"},
+	{time.Minute, "minute", "minutes", "m"},
+	{time.Second, "second", "seconds", "s"},
+	{time.Millisecond, "millisecond", "milliseconds", "ms"},
+	{time.Microsecond, "microsecond", "microseconds", "µs"},
+	{time.Nanosecond, "nanosecond", "nanoseconds", "ns"},
+}
+
+// FormatHuman returns a human friendly representation of a duration such as
+// "2 hours 5 minutes". Days are 24 hours long.
+func FormatHuman(d time.Duration) string {
+	var parts []string
+	formatUnits(d, func(n int64, i int) {
+		name := humanUnits[i].many
+		if n == 1 {
+			name = humanUnits[i].one
+		}
+		parts = append(parts, strconv.FormatInt(n, 10)+" "+name)
+	})
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+
+	s := strings.Join(parts, " ")
+	if d < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// FormatCompact returns a compact representation of a duration such as
+// "1d2h5m", which can be parsed by ParseDuration. Days are 24 hours long.
+func FormatCompact(d time.Duration) string {
+	var b []byte
+	if d < 0 {
+		b = append(b, '-')
+	}
+	formatUnits(d, func(n int64, i int) {
+		b = strconv.AppendInt(b, n, 10)
+		b = append(b, humanUnits[i].abr...)
+	})
+	if len(b) == 0 || b[len(b)-1] == '-' {
+		return "0s"
+	}
+	return string(b)
+}
+
+// formatUnits calls fn for each non-zero unit of the absolute value of d,
+// from the largest to the smallest.
+func formatUnits(d time.Duration, fn func(n int64, i int)) {
+	// Work on the negative value, which can represent the minimum duration.
+	if d > 0 {
+		d = -d
+	}
+	for i, u := range humanUnits {
+		if n := -int64(d / u.unit); n != 0 {
+			fn(n, i)
+			d %= u.unit
+		}
+	}
+}
+
+// Duration allows un/marshaling of durations in the format accepted by
+// ParseDuration, e.g. "timeout": "1d".
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(FormatCompact(d.Duration))), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Numbers are
+// interpreted as nanoseconds, like for time.Duration.
+func (d *Duration) UnmarshalJSON(json []byte) error {
+	s := string(json)
+	if s == "null" {
+		return nil
+	}
+	if s == "" || s[0] != '"' {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s", s)
+		}
+		d.Duration = time.Duration(n)
+		return nil
+	}
+
+	s, err := strconv.Unquote(s)
+	if err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(FormatCompact(d.Duration)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parse, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parse
+	return nil
+}
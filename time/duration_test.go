@@ -0,0 +1,83 @@
+package time
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected time.Duration
+	}{
+		{"0", 0},
+		{"300ms", 300 * time.Millisecond},
+		{"-1.5h", -90 * time.Minute},
+		{"1h2m3s4ms5us6ns", time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond + 5*time.Microsecond + 6},
+		{"3d12h", 84 * time.Hour},
+		{"2w", 14 * Day},
+		{"1.5d", 36 * time.Hour},
+		{"P1DT2H", 26 * time.Hour},
+		{"PT0.5S", 500 * time.Millisecond},
+		{"P2W", 14 * Day},
+		{"PT1H30M", 90 * time.Minute},
+		{"-PT1M", -time.Minute},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseDuration(tt.s)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.s, err)
+		}
+		if actual != tt.expected {
+			t.Fatalf("%s: %s != %s", tt.s, tt.expected, actual)
+		}
+	}
+
+	for _, s := range []string{"", "1", "1x", "P", "PT", "P1Y", "P1M", "PT1D", "1d-2h", "9999999999999d"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Fatalf("%s: expected error", s)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d              time.Duration
+		human, compact string
+	}{
+		{0, "0 seconds", "0s"},
+		{2*time.Hour + 5*time.Minute, "2 hours 5 minutes", "2h5m"},
+		{Day + time.Second, "1 day 1 second", "1d1s"},
+		{-1500 * time.Millisecond, "-1 second 500 milliseconds", "-1s500ms"},
+	}
+
+	for _, tt := range tests {
+		if actual := FormatHuman(tt.d); actual != tt.human {
+			t.Fatalf("%q != %q", tt.human, actual)
+		}
+		if actual := FormatCompact(tt.d); actual != tt.compact {
+			t.Fatalf("%q != %q", tt.compact, actual)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	var v struct {
+		Timeout Duration `json:"timeout"`
+	}
+	if err := json.Unmarshal([]byte(`{"timeout":"1d"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Timeout.Duration != Day {
+		t.Fatalf("%s != %s", Day, v.Timeout)
+	}
+	data, _ := json.Marshal(v)
+	if string(data) != `{"timeout":"1d"}` {
+		t.Fatalf(`{"timeout":"1d"} != %s`, data)
+	}
+	if err := json.Unmarshal([]byte(`{"timeout":1000}`), &v); err != nil || v.Timeout.Duration != time.Microsecond {
+		t.Fatalf("unexpected result: %s, %v", v.Timeout, err)
+	}
+}
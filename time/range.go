@@ -0,0 +1,103 @@
+package time
+
+import (
+	"time"
+)
+
+// TimeRange is the half-open time interval [Start, End).
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// NewTimeRange returns the time range [start, end). If end lies before start,
+// they are swapped.
+func NewTimeRange(start, end time.Time) TimeRange {
+	if end.Before(start) {
+		start, end = end, start
+	}
+	return TimeRange{start, end}
+}
+
+// IsEmpty reports whether the range contains no instant.
+func (r TimeRange) IsEmpty() bool {
+	return !r.Start.Before(r.End)
+}
+
+// Duration returns the length of the range.
+func (r TimeRange) Duration() time.Duration {
+	if r.IsEmpty() {
+		return 0
+	}
+	return r.End.Sub(r.Start)
+}
+
+// Contains reports whether t lies within the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// Overlaps reports whether the ranges share at least one instant.
+func (r TimeRange) Overlaps(o TimeRange) bool {
+	return r.Start.Before(o.End) && o.Start.Before(r.End)
+}
+
+// Intersect returns the range shared by both ranges and whether it is
+// non-empty.
+func (r TimeRange) Intersect(o TimeRange) (TimeRange, bool) {
+	if !r.Overlaps(o) {
+		return TimeRange{}, false
+	}
+	i := r
+	if o.Start.After(i.Start) {
+		i.Start = o.Start
+	}
+	if o.End.Before(i.End) {
+		i.End = o.End
+	}
+	return i, true
+}
+
+// SplitDays splits the range at midnight in a given location. Days spanning a
+// daylight saving time transition are 23 or 25 hours long accordingly.
+func (r TimeRange) SplitDays(loc *time.Location) []TimeRange {
+	return r.split(func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+	})
+}
+
+// SplitHours splits the range at full hours in a given location, which
+// matters for locations with offsets of fractional hours.
+func (r TimeRange) SplitHours(loc *time.Location) []TimeRange {
+	return r.split(func(t time.Time) time.Time {
+		_, offset := t.In(loc).Zone()
+		shift := time.Duration(offset) * time.Second
+		return t.Add(shift).Truncate(time.Hour).Add(time.Hour - shift)
+	})
+}
+
+// Days returns midnight in a given location of each day the range touches.
+func (r TimeRange) Days(loc *time.Location) []time.Time {
+	var days []time.Time
+	for _, d := range r.SplitDays(loc) {
+		t := d.Start.In(loc)
+		days = append(days, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc))
+	}
+	return days
+}
+
+// split splits the range at the boundaries returned by next, which must
+// return the first boundary after a given time.
+func (r TimeRange) split(next func(time.Time) time.Time) []TimeRange {
+	var parts []TimeRange
+	for start := r.Start; start.Before(r.End); {
+		end := next(start)
+		if !end.After(start) || end.After(r.End) {
+			end = r.End
+		}
+		parts = append(parts, TimeRange{start, end})
+		start = end
+	}
+	return parts
+}
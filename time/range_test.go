@@ -0,0 +1,73 @@
+package time
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeRange(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2021, 1, 1, h, 0, 0, 0, time.UTC) }
+	r := NewTimeRange(at(12), at(8))
+
+	if r.Start != at(8) || r.Duration() != 4*time.Hour {
+		t.Fatalf("unexpected range %v", r)
+	}
+	if !r.Contains(at(8)) || r.Contains(at(12)) || r.Contains(at(7)) {
+		t.Fatal("unexpected containment")
+	}
+	if r.Overlaps(TimeRange{at(12), at(14)}) || !r.Overlaps(TimeRange{at(11), at(14)}) {
+		t.Fatal("unexpected overlap")
+	}
+
+	i, ok := r.Intersect(TimeRange{at(10), at(14)})
+	if !ok || i != (TimeRange{at(10), at(12)}) {
+		t.Fatalf("unexpected intersection %v", i)
+	}
+	if _, ok := r.Intersect(TimeRange{at(12), at(14)}); ok {
+		t.Fatal("expected empty intersection")
+	}
+}
+
+func TestTimeRangeSplitDays(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// Daylight saving time starts on March 28th 2021 in Berlin.
+	r := TimeRange{
+		time.Date(2021, 3, 27, 12, 0, 0, 0, loc),
+		time.Date(2021, 3, 29, 12, 0, 0, 0, loc),
+	}
+	parts := r.SplitDays(loc)
+	expected := []time.Duration{12 * time.Hour, 23 * time.Hour, 12 * time.Hour}
+	if len(parts) != len(expected) {
+		t.Fatalf("%d != %d", len(expected), len(parts))
+	}
+	for i, p := range parts {
+		if p.Duration() != expected[i] {
+			t.Fatalf("%d: %s != %s", i, expected[i], p.Duration())
+		}
+	}
+
+	days := r.Days(loc)
+	if len(days) != 3 || days[1] != time.Date(2021, 3, 28, 0, 0, 0, 0, loc) {
+		t.Fatalf("unexpected days %v", days)
+	}
+}
+
+func TestTimeRangeSplitHours(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	start := time.Date(2021, 1, 1, 10, 15, 0, 0, loc)
+	parts := TimeRange{start, start.Add(2 * time.Hour)}.SplitHours(loc)
+
+	if len(parts) != 3 {
+		t.Fatalf("3 != %d", len(parts))
+	}
+	if end := parts[0].End.In(loc); end.Hour() != 11 || end.Minute() != 0 {
+		t.Fatalf("unexpected boundary %s", end)
+	}
+	if parts[2].Duration() != 15*time.Minute {
+		t.Fatalf("unexpected last part %v", parts[2])
+	}
+}
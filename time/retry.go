@@ -0,0 +1,98 @@
+package time
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// permanentError marks an error as not to be retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Cause() error  { return e.err }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error to signal Retry to stop retrying. If err is nil,
+// Permanent returns nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// RetryError holds the errors of all failed attempts of Retry, in order.
+type RetryError struct {
+	Errors []error
+	// Err is the context's error if the context ended the retries.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%d attempts failed before %v: %s", len(e.Errors), e.Err, strings.Join(msgs, "; "))
+	}
+	return fmt.Sprintf("%d attempts failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Cause returns the error which ended the retries, i.e. the context's error
+// or the last attempt's error.
+func (e *RetryError) Cause() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return e.Errors[len(e.Errors)-1]
+}
+
+// Unwrap returns the error which ended the retries.
+func (e *RetryError) Unwrap() error { return e.Cause() }
+
+// Retry calls fn until it succeeds, sleeping between attempts according to a
+// backoff configuration. Retry gives up after maxAttempts attempts, unless
+// maxAttempts is zero, when Backoff.MaxElapsed has passed, when the context is
+// done, or when fn returns an error wrapped by Permanent. In that case Retry
+// returns a *RetryError holding the errors of all attempts and, if the context
+// ended the retries, the context's error.
+func Retry(ctx context.Context, backoff Backoff, maxAttempts int, fn func() error) error {
+	var errs []error
+	clock := clockOrReal(backoff.Clock)
+	start := clock.Now()
+	interval := backoff.Initial
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var perm permanentError
+		if errors.As(err, &perm) {
+			errs = append(errs, perm.err)
+			return &RetryError{Errors: errs}
+		}
+		errs = append(errs, err)
+
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return &RetryError{Errors: errs}
+		}
+		if backoff.MaxElapsed > 0 && clock.Now().Sub(start)+interval >= backoff.MaxElapsed {
+			return &RetryError{Errors: errs}
+		}
+
+		timer := clock.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &RetryError{Errors: errs, Err: ctx.Err()}
+		case <-timer.C():
+		}
+		interval = backoff.next(interval, attempt)
+	}
+}
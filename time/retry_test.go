@@ -0,0 +1,87 @@
+package time
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), Backoff{Strategy: Exponential, Initial: time.Millisecond}, 5, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("unexpected result: %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), Backoff{Initial: time.Millisecond}, 3, func() error {
+		calls++
+		return errors.New("fail")
+	})
+	retryErr, ok := err.(*RetryError)
+	if !ok || len(retryErr.Errors) != 3 || calls != 3 {
+		t.Fatalf("unexpected result: %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryPermanent(t *testing.T) {
+	e := errors.New("permanent")
+	calls := 0
+	err := Retry(context.Background(), Backoff{Initial: time.Millisecond}, 0, func() error {
+		calls++
+		return Permanent(e)
+	})
+	if calls != 1 || err.(*RetryError).Cause() != e {
+		t.Fatalf("unexpected result: %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Retry(ctx, Backoff{Initial: time.Hour}, 0, func() error {
+		cancel()
+		return errors.New("fail")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("%v != %v", context.Canceled, err)
+	}
+	expected := "1 attempts failed before context canceled: fail"
+	if len(err.(*RetryError).Errors) != 1 || err.Error() != expected {
+		t.Fatalf("%q != %q", expected, err)
+	}
+}
+
+func TestJitterStrategies(t *testing.T) {
+	base, limit := 10*time.Millisecond, 100*time.Millisecond
+	for _, strategy := range []AttemptStrategy{
+		FullJitter(base, limit),
+		EqualJitter(base, limit),
+		DecorrelatedJitter(base, limit),
+	} {
+		var prev time.Duration
+		for attempt := 1; attempt <= 20; attempt++ {
+			prev = strategy(attempt, prev)
+			if prev < 0 || prev > limit {
+				t.Fatalf("interval out of bounds: %s", prev)
+			}
+		}
+	}
+}
+
+func TestExponentialInterval(t *testing.T) {
+	base, limit := 10*time.Millisecond, 100*time.Millisecond
+	for attempt, expected := range []time.Duration{10, 10, 20, 40, 80, 100, 100} {
+		if d := exponentialInterval(base, limit, attempt); d != expected*time.Millisecond {
+			t.Fatalf("%d: %s != %s", attempt, expected*time.Millisecond, d)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package time
+
+import (
+	"context"
+	"sync"
+)
+
+// OverlapPolicy defines what a Scheduler does when a job is due while its
+// previous run has not finished yet.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the run.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs the job again once the previous run finished. Runs
+	// are executed one after another; runs still queued on Stop are dropped.
+	OverlapQueue
+	// OverlapConcurrent runs the job concurrently with the previous run.
+	OverlapConcurrent
+)
+
+// Job is a function run by a Scheduler. The context is canceled when the
+// scheduler fails to stop gracefully.
+type Job func(ctx context.Context)
+
+// Scheduler runs jobs according to their schedules.
+type Scheduler struct {
+	clock Clock
+
+	mu      sync.Mutex
+	entries []*entry
+	started bool
+	stopped bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stopCh chan struct{}
+	loops  sync.WaitGroup
+	runs   sync.WaitGroup
+}
+
+type entry struct {
+	schedule Schedule
+	policy   OverlapPolicy
+	job      Job
+
+	mu      sync.Mutex
+	running bool
+	pending int
+}
+
+// NewScheduler returns a new Scheduler measuring time with a given clock. If
+// clock is nil, RealClock is used.
+func NewScheduler(clock Clock) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		clock:  clockOrReal(clock),
+		ctx:    ctx,
+		cancel: cancel,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Add parses a cron expression as described by ParseCron and adds a job
+// running on its schedule.
+func (s *Scheduler) Add(spec string, policy OverlapPolicy, job Job) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	s.AddSchedule(schedule, policy, job)
+	return nil
+}
+
+// AddSchedule adds a job running on a given schedule. Jobs added to a
+// started scheduler are scheduled immediately; jobs added to a stopped
+// scheduler never run.
+func (s *Scheduler) AddSchedule(schedule Schedule, policy OverlapPolicy, job Job) {
+	e := &entry{schedule: schedule, policy: policy, job: job}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	if s.started && !s.stopped {
+		s.loops.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Start starts scheduling jobs. Calling Start more than once has no effect.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.stopped {
+		return
+	}
+
+	s.started = true
+	for _, e := range s.entries {
+		s.loops.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Stop stops scheduling jobs and waits for running jobs to finish. If the
+// context is done before, the contexts passed to the running jobs are
+// canceled and the context's error is returned.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stopCh)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.loops.Wait()
+		s.runs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(e *entry) {
+	defer s.loops.Done()
+
+	now := s.clock.Now()
+	for {
+		next := e.schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+
+		timer := s.clock.NewTimer(next.Sub(now))
+		select {
+		case <-s.stopCh:
+			timer.Stop()
+			return
+		case now = <-timer.C():
+		}
+		if now.Before(next) {
+			now = next
+		}
+		s.dispatch(e)
+	}
+}
+
+func (s *Scheduler) dispatch(e *entry) {
+	switch e.policy {
+	case OverlapConcurrent:
+		s.runs.Add(1)
+		go s.run(e, false)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		if e.policy == OverlapQueue {
+			e.pending++
+		}
+		return
+	}
+
+	e.running = true
+	s.runs.Add(1)
+	go s.run(e, true)
+}
+
+// run runs a job. Exclusive runs continue with pending runs of the entry.
+func (s *Scheduler) run(e *entry, exclusive bool) {
+	defer s.runs.Done()
+
+	for {
+		e.job(s.ctx)
+		if !exclusive {
+			return
+		}
+
+		e.mu.Lock()
+		if e.pending == 0 || s.isStopped() {
+			e.running = false
+			e.pending = 0
+			e.mu.Unlock()
+			return
+		}
+		e.pending--
+		e.mu.Unlock()
+	}
+}
+
+func (s *Scheduler) isStopped() bool {
+	select {
+	case <-s.stopCh:
+		return true
+	default:
+		return false
+	}
+}
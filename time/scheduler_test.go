@@ -0,0 +1,81 @@
+package time
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerOverlap(t *testing.T) {
+	for _, tt := range []struct {
+		policy   OverlapPolicy
+		expected int
+	}{
+		{OverlapSkip, 1},
+		{OverlapQueue, 3},
+		{OverlapConcurrent, 3},
+	} {
+		clock := NewFakeClock(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+		s := NewScheduler(clock)
+
+		var mu sync.Mutex
+		runs := 0
+		release := make(chan struct{})
+		s.AddSchedule(Every(time.Minute), tt.policy, func(ctx context.Context) {
+			mu.Lock()
+			runs++
+			mu.Unlock()
+			<-release
+		})
+		s.Start()
+
+		for i := 0; i < 3; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute)
+		}
+		clock.BlockUntil(1)
+		close(release)
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			n := runs
+			mu.Unlock()
+			if n == tt.expected {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("policy %d: %d != %d", tt.policy, tt.expected, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSchedulerStopTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	s := NewScheduler(clock)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	s.AddSchedule(Every(time.Second), OverlapSkip, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+	})
+	s.Start()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Stop(ctx); err != context.Canceled {
+		t.Fatalf("%v != %v", context.Canceled, err)
+	}
+	<-canceled
+}
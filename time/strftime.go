@@ -0,0 +1,723 @@
+package time
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directive is a parsed conversion specification such as "%-d" or "%3N".
+type directive struct {
+	flag   byte // one of '-', '_', '0', '^', '#' or 0
+	width  int  // explicit field width or 0
+	colons int  // number of ':' preceding z
+	verb   byte
+}
+
+// composites maps conversion specifications equivalent to a sequence of
+// other conversion specifications. Output is locale-independent and equals
+// the POSIX locale's.
+var composites = map[byte]string{
+	'c': "%a %b %e %H:%M:%S %Y",
+	'D': "%m/%d/%y",
+	'F': "%Y-%m-%d",
+	'r': "%I:%M:%S %p",
+	'R': "%H:%M",
+	'T': "%H:%M:%S",
+	'x': "%m/%d/%y",
+	'X': "%H:%M:%S",
+	'+': "%a %b %e %H:%M:%S %Z %Y",
+}
+
+// numerics maps numeric conversion specifications to their default width and
+// padding character.
+var numerics = map[byte]struct {
+	width int
+	pad   byte
+}{
+	'C': {2, '0'},
+	'd': {2, '0'},
+	'e': {2, ' '},
+	'g': {2, '0'},
+	'G': {4, '0'},
+	'H': {2, '0'},
+	'I': {2, '0'},
+	'j': {3, '0'},
+	'k': {2, ' '},
+	'l': {2, ' '},
+	'm': {2, '0'},
+	'M': {2, '0'},
+	's': {1, '0'},
+	'S': {2, '0'},
+	'u': {1, '0'},
+	'U': {2, '0'},
+	'V': {2, '0'},
+	'w': {1, '0'},
+	'W': {2, '0'},
+	'y': {2, '0'},
+	'Y': {4, '0'},
+}
+
+var (
+	longDayNames   = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	shortDayNames  = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	longMonthNames = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	shortMonthNames = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+		"Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+)
+
+// nextDirective parses the conversion specification starting after the '%'
+// at layout[i]. It returns the directive and the index following it. The
+// POSIX E and O modifiers are accepted and ignored.
+func nextDirective(layout string, i int) (directive, int, bool) {
+	var d directive
+	i++
+	if i < len(layout) && strings.IndexByte("-_0^#", layout[i]) >= 0 {
+		d.flag = layout[i]
+		i++
+	}
+	for i < len(layout) && '0' <= layout[i] && layout[i] <= '9' {
+		d.width = d.width*10 + int(layout[i]-'0')
+		i++
+	}
+	if i < len(layout) && (layout[i] == 'E' || layout[i] == 'O') {
+		i++
+	}
+	for i < len(layout) && layout[i] == ':' {
+		d.colons++
+		i++
+	}
+	if i >= len(layout) {
+		return d, i, false
+	}
+	d.verb = layout[i]
+	return d, i + 1, true
+}
+
+// strftime formats a time according to a strftime layout.
+func strftime(t time.Time, layout string) string {
+	b := make([]byte, 0, len(layout)+10)
+	return string(appendStrftime(b, t, layout))
+}
+
+func appendStrftime(b []byte, t time.Time, layout string) []byte {
+	for i := 0; i < len(layout); {
+		if layout[i] != '%' {
+			b = append(b, layout[i])
+			i++
+			continue
+		}
+
+		d, next, ok := nextDirective(layout, i)
+		if !ok {
+			// Copy an incomplete trailing conversion specification as is.
+			return append(b, layout[i:]...)
+		}
+		b = appendDirective(b, t, d, layout[i:next])
+		i = next
+	}
+	return b
+}
+
+func appendDirective(b []byte, t time.Time, d directive, spec string) []byte {
+	if c, ok := composites[d.verb]; ok {
+		return appendText(b, d, strftime(t, c))
+	}
+	if _, ok := numerics[d.verb]; ok {
+		return appendNumber(b, d, numericValue(t, d.verb))
+	}
+
+	switch d.verb {
+	case 'a':
+		return appendText(b, d, shortDayNames[t.Weekday()])
+	case 'A':
+		return appendText(b, d, longDayNames[t.Weekday()])
+	case 'b', 'h':
+		return appendText(b, d, shortMonthNames[t.Month()-1])
+	case 'B':
+		return appendText(b, d, longMonthNames[t.Month()-1])
+	case 'p':
+		if t.Hour() < 12 {
+			return appendText(b, d, "AM")
+		}
+		return appendText(b, d, "PM")
+	case 'P':
+		if t.Hour() < 12 {
+			return appendText(b, d, "am")
+		}
+		return appendText(b, d, "pm")
+	case 'N':
+		digits := fmt.Sprintf("%09d", t.Nanosecond())
+		if d.width > 0 && d.width < 9 {
+			digits = digits[:d.width]
+		}
+		return append(b, digits...)
+	case 'z':
+		return appendOffset(b, t, d.colons)
+	case 'Z':
+		name, _ := t.Zone()
+		return appendText(b, d, name)
+	case 'n':
+		return append(b, '\n')
+	case 't':
+		return append(b, '\t')
+	case '%':
+		return append(b, '%')
+	}
+
+	// Unknown conversion specifications are copied as is.
+	return append(b, spec...)
+}
+
+func numericValue(t time.Time, verb byte) int64 {
+	switch verb {
+	case 'C':
+		return int64(floorDiv(t.Year(), 100))
+	case 'd', 'e':
+		return int64(t.Day())
+	case 'g':
+		year, _ := t.ISOWeek()
+		return int64(mod(year, 100))
+	case 'G':
+		year, _ := t.ISOWeek()
+		return int64(year)
+	case 'H', 'k':
+		return int64(t.Hour())
+	case 'I', 'l':
+		h := t.Hour() % 12
+		if h == 0 {
+			h = 12
+		}
+		return int64(h)
+	case 'j':
+		return int64(t.YearDay())
+	case 'm':
+		return int64(t.Month())
+	case 'M':
+		return int64(t.Minute())
+	case 's':
+		return t.Unix()
+	case 'S':
+		return int64(t.Second())
+	case 'u':
+		wd := int64(t.Weekday())
+		if wd == 0 {
+			wd = 7
+		}
+		return wd
+	case 'U':
+		return int64((t.YearDay() + 6 - int(t.Weekday())) / 7)
+	case 'V':
+		_, week := t.ISOWeek()
+		return int64(week)
+	case 'w':
+		return int64(t.Weekday())
+	case 'W':
+		return int64((t.YearDay() + 6 - (int(t.Weekday())+6)%7) / 7)
+	case 'y':
+		return int64(mod(t.Year(), 100))
+	case 'Y':
+		return int64(t.Year())
+	}
+	return 0
+}
+
+func appendNumber(b []byte, d directive, v int64) []byte {
+	def := numerics[d.verb]
+	width, pad := def.width, def.pad
+	if d.width > 0 {
+		width = d.width
+	}
+	switch d.flag {
+	case '-':
+		pad = 0
+	case '_':
+		pad = ' '
+	case '0':
+		pad = '0'
+	}
+
+	if v < 0 {
+		b = append(b, '-')
+		v = -v
+		width--
+	}
+	s := strconv.FormatInt(v, 10)
+	if pad != 0 {
+		for n := len(s); n < width; n++ {
+			b = append(b, pad)
+		}
+	}
+	return append(b, s...)
+}
+
+func appendText(b []byte, d directive, s string) []byte {
+	switch d.flag {
+	case '^':
+		s = strings.ToUpper(s)
+	case '#':
+		if d.verb == 'p' || d.verb == 'Z' {
+			s = strings.ToLower(s)
+		} else {
+			s = strings.ToUpper(s)
+		}
+	}
+	pad := byte(' ')
+	if d.flag == '0' {
+		pad = '0'
+	}
+	for n := len(s); n < d.width; n++ {
+		b = append(b, pad)
+	}
+	return append(b, s...)
+}
+
+func appendOffset(b []byte, t time.Time, colons int) []byte {
+	_, offset := t.Zone()
+	if offset < 0 {
+		b = append(b, '-')
+		offset = -offset
+	} else {
+		b = append(b, '+')
+	}
+	b = appendInt2(b, offset/3600)
+	if colons > 0 {
+		b = append(b, ':')
+	}
+	b = appendInt2(b, offset/60%60)
+	if colons > 1 {
+		b = append(b, ':')
+		b = appendInt2(b, offset%60)
+	}
+	return b
+}
+
+func appendInt2(b []byte, v int) []byte {
+	return append(b, byte('0'+v/10), byte('0'+v%10))
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+func mod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// fields collects the values parsed by strptime.
+type fields struct {
+	year, yy, century, isoYear, isoYY     int
+	hasYear, hasYY, hasCentury            bool
+	hasISOYear, hasISOYY                  bool
+	month, day, yday                      int
+	hasMonth, hasDay, hasYday             bool
+	hour, min, sec, nsec                  int
+	hour12, pm, hasAMPM                   bool
+	wday, weekU, weekW, weekV             int
+	hasWday, hasWeekU, hasWeekW, hasWeekV bool
+	epoch                                 int64
+	hasEpoch                              bool
+	offset                                int
+	hasOffset                             bool
+	zone                                  string
+}
+
+// strptime parses a value according to a strftime layout and returns the
+// time it represents. Without time zone information the time is interpreted
+// in the given location.
+func strptime(layout, value string, loc *time.Location) (time.Time, error) {
+	f := fields{month: 1, day: 1}
+	rest, err := f.parse(layout, value)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("extra text %q", rest)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time %q as %q: %s", value, layout, err)
+	}
+
+	t, err := f.time(loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time %q as %q: %s", value, layout, err)
+	}
+	return t, nil
+}
+
+func (f *fields) parse(layout, value string) (string, error) {
+	for i := 0; i < len(layout); {
+		c := layout[i]
+		if isSpace(c) {
+			value = strings.TrimLeft(value, " \t\n\r\v\f")
+			i++
+			continue
+		}
+		if c != '%' {
+			if value == "" || value[0] != c {
+				return value, fmt.Errorf("cannot parse %q as %q", value, layout[i:])
+			}
+			value = value[1:]
+			i++
+			continue
+		}
+
+		d, next, ok := nextDirective(layout, i)
+		if !ok {
+			return value, fmt.Errorf("incomplete conversion specification %q", layout[i:])
+		}
+		var err error
+		value, err = f.parseDirective(d, value)
+		if err != nil {
+			return value, err
+		}
+		i = next
+	}
+	return value, nil
+}
+
+func (f *fields) parseDirective(d directive, value string) (string, error) {
+	if c, ok := composites[d.verb]; ok {
+		return f.parse(c, value)
+	}
+
+	if def, ok := numerics[d.verb]; ok {
+		width := def.width
+		if d.width > 0 {
+			width = d.width
+		}
+		if d.verb == 's' {
+			width = 19
+		}
+		v, rest, err := parseNumber(value, width, d.verb == 's' || d.verb == 'Y' || d.verb == 'G')
+		if err != nil {
+			return value, fmt.Errorf("cannot parse %q as %%%c: %s", value, d.verb, err)
+		}
+		if err := f.setNumber(d.verb, v); err != nil {
+			return value, err
+		}
+		return rest, nil
+	}
+
+	switch d.verb {
+	case 'a', 'A':
+		i, rest, err := parseName(value, longDayNames, shortDayNames)
+		if err != nil {
+			return value, fmt.Errorf("cannot parse %q as %%%c: %s", value, d.verb, err)
+		}
+		f.wday, f.hasWday = i, true
+		return rest, nil
+	case 'b', 'B', 'h':
+		i, rest, err := parseName(value, longMonthNames, shortMonthNames)
+		if err != nil {
+			return value, fmt.Errorf("cannot parse %q as %%%c: %s", value, d.verb, err)
+		}
+		f.month, f.hasMonth = i+1, true
+		return rest, nil
+	case 'p', 'P':
+		i, rest, err := parseName(value, []string{"AM", "PM"})
+		if err != nil {
+			return value, fmt.Errorf("cannot parse %q as %%%c: %s", value, d.verb, err)
+		}
+		f.pm, f.hasAMPM = i == 1, true
+		return rest, nil
+	case 'N':
+		width := 9
+		if d.width > 0 && d.width < 9 {
+			width = d.width
+		}
+		n := 0
+		for n < width && n < len(value) && isDigit(value[n]) {
+			n++
+		}
+		if n == 0 {
+			return value, fmt.Errorf("cannot parse %q as %%N", value)
+		}
+		v, _ := strconv.Atoi(value[:n])
+		for i := n; i < 9; i++ {
+			v *= 10
+		}
+		f.nsec = v
+		return value[n:], nil
+	case 'z':
+		offset, rest, err := parseOffset(value)
+		if err != nil {
+			return value, fmt.Errorf("cannot parse %q as %%z: %s", value, err)
+		}
+		f.offset, f.hasOffset = offset, true
+		return rest, nil
+	case 'Z':
+		n := 0
+		for n < len(value) && ('A' <= value[n] && value[n] <= 'Z' || 'a' <= value[n] && value[n] <= 'z') {
+			n++
+		}
+		if n == 0 {
+			return value, fmt.Errorf("cannot parse %q as %%Z", value)
+		}
+		f.zone = value[:n]
+		return value[n:], nil
+	case 'n', 't':
+		return strings.TrimLeft(value, " \t\n\r\v\f"), nil
+	case '%':
+		if value == "" || value[0] != '%' {
+			return value, fmt.Errorf("cannot parse %q as %%%%", value)
+		}
+		return value[1:], nil
+	}
+
+	return value, fmt.Errorf("unsupported conversion specification %%%c", d.verb)
+}
+
+func (f *fields) setNumber(verb byte, v int64) error {
+	n := int(v)
+	check := func(min, max int) error {
+		if n < min || n > max {
+			return fmt.Errorf("%%%c out of range: %d", verb, n)
+		}
+		return nil
+	}
+
+	switch verb {
+	case 'C':
+		f.century, f.hasCentury = n, true
+	case 'd', 'e':
+		f.day, f.hasDay = n, true
+		return check(1, 31)
+	case 'g':
+		f.isoYY, f.hasISOYY = n, true
+		return check(0, 99)
+	case 'G':
+		f.isoYear, f.hasISOYear = n, true
+	case 'H', 'k':
+		f.hour, f.hour12 = n, false
+		return check(0, 23)
+	case 'I', 'l':
+		f.hour, f.hour12 = n, true
+		return check(1, 12)
+	case 'j':
+		f.yday, f.hasYday = n, true
+		return check(1, 366)
+	case 'm':
+		f.month, f.hasMonth = n, true
+		return check(1, 12)
+	case 'M':
+		f.min = n
+		return check(0, 59)
+	case 's':
+		f.epoch, f.hasEpoch = v, true
+	case 'S':
+		f.sec = n
+		return check(0, 60)
+	case 'u':
+		f.wday, f.hasWday = n%7, true
+		return check(1, 7)
+	case 'U':
+		f.weekU, f.hasWeekU = n, true
+		return check(0, 53)
+	case 'V':
+		f.weekV, f.hasWeekV = n, true
+		return check(1, 53)
+	case 'w':
+		f.wday, f.hasWday = n, true
+		return check(0, 6)
+	case 'W':
+		f.weekW, f.hasWeekW = n, true
+		return check(0, 53)
+	case 'y':
+		f.yy, f.hasYY = n, true
+		return check(0, 99)
+	case 'Y':
+		f.year, f.hasYear = n, true
+	}
+	return nil
+}
+
+// time resolves the parsed fields into a time.
+func (f *fields) time(loc *time.Location) (time.Time, error) {
+	if f.hasEpoch {
+		t := time.Unix(f.epoch, int64(f.nsec))
+		return t.In(f.location(t, loc)), nil
+	}
+
+	year := pivotYear(f.year, f.hasYear, f.yy, f.hasYY, f.century, f.hasCentury)
+	isoYear := pivotYear(f.isoYear, f.hasISOYear, f.isoYY, f.hasISOYY, f.century, f.hasCentury)
+	if !f.hasISOYear && !f.hasISOYY {
+		isoYear = year
+	}
+
+	var date time.Time
+	switch {
+	case f.hasMonth || f.hasDay:
+		date = time.Date(year, time.Month(f.month), f.day, 0, 0, 0, 0, time.UTC)
+		if date.Day() != f.day {
+			return time.Time{}, fmt.Errorf("day out of range")
+		}
+	case f.hasYday:
+		date = time.Date(year, time.January, f.yday, 0, 0, 0, 0, time.UTC)
+		if date.Year() != year {
+			return time.Time{}, fmt.Errorf("day of year out of range")
+		}
+	case f.hasWeekV && f.hasWday:
+		jan4 := time.Date(isoYear, time.January, 4, 0, 0, 0, 0, time.UTC)
+		monday := 4 - (int(jan4.Weekday())+6)%7
+		date = time.Date(isoYear, time.January, monday+(f.weekV-1)*7+(f.wday+6)%7, 0, 0, 0, 0, time.UTC)
+	case f.hasWeekU && f.hasWday:
+		jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		sunday := 1 + (7-int(jan1.Weekday()))%7
+		date = time.Date(year, time.January, sunday+(f.weekU-1)*7+f.wday, 0, 0, 0, 0, time.UTC)
+	case f.hasWeekW && f.hasWday:
+		jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		monday := 1 + (8-int(jan1.Weekday()))%7
+		date = time.Date(year, time.January, monday+(f.weekW-1)*7+(f.wday+6)%7, 0, 0, 0, 0, time.UTC)
+	default:
+		date = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	hour := f.hour
+	if f.hour12 {
+		hour %= 12
+		if f.pm {
+			hour += 12
+		}
+	}
+	if f.sec == 60 {
+		return time.Time{}, fmt.Errorf("second out of range")
+	}
+
+	t := time.Date(date.Year(), date.Month(), date.Day(), hour, f.min, f.sec, f.nsec, time.UTC)
+	if f.hasOffset {
+		t = t.Add(-time.Duration(f.offset) * time.Second)
+		return t.In(f.location(t, loc)), nil
+	}
+	if f.zone != "" {
+		local := time.Date(t.Year(), t.Month(), t.Day(), hour, f.min, f.sec, f.nsec, loc)
+		if l := f.location(local, loc); l != loc {
+			return time.Date(t.Year(), t.Month(), t.Day(), hour, f.min, f.sec, f.nsec, l), nil
+		}
+		return local, nil
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, f.min, f.sec, f.nsec, loc), nil
+}
+
+// location returns the location described by a parsed offset and zone
+// abbreviation at time t, preferring loc if it matches them as package time's
+// Parse does.
+func (f *fields) location(t time.Time, loc *time.Location) *time.Location {
+	name, offset := t.In(loc).Zone()
+
+	if !f.hasOffset {
+		switch {
+		case f.zone == "":
+			return loc
+		case f.zone == name:
+			return loc
+		case f.zone == "UTC" || f.zone == "GMT":
+			return time.UTC
+		}
+		return time.FixedZone(f.zone, 0)
+	}
+
+	if offset == f.offset && (f.zone == "" || f.zone == name) {
+		return loc
+	}
+	if f.offset == 0 && f.zone == "" {
+		return time.UTC
+	}
+	return time.FixedZone(f.zone, f.offset)
+}
+
+func pivotYear(year int, hasYear bool, yy int, hasYY bool, century int, hasCentury bool) int {
+	switch {
+	case hasYear:
+		return year
+	case hasYY && hasCentury:
+		return century*100 + yy
+	case hasYY && yy >= 69:
+		return 1900 + yy
+	case hasYY:
+		return 2000 + yy
+	case hasCentury:
+		return century * 100
+	}
+	return 0
+}
+
+func parseNumber(value string, width int, signed bool) (int64, string, error) {
+	value = strings.TrimLeft(value, " ")
+	neg := false
+	if signed && value != "" && (value[0] == '-' || value[0] == '+') {
+		neg = value[0] == '-'
+		value = value[1:]
+	}
+	n := 0
+	for n < width && n < len(value) && isDigit(value[n]) {
+		n++
+	}
+	if n == 0 {
+		return 0, value, fmt.Errorf("expected digits")
+	}
+	v, err := strconv.ParseInt(value[:n], 10, 64)
+	if err != nil {
+		return 0, value, err
+	}
+	if neg {
+		v = -v
+	}
+	return v, value[n:], nil
+}
+
+// parseName matches the longest of the given names as a case-insensitive
+// prefix of value and returns its index.
+func parseName(value string, names ...[]string) (int, string, error) {
+	for _, list := range names {
+		for i, name := range list {
+			if len(value) >= len(name) && strings.EqualFold(value[:len(name)], name) {
+				return i, value[len(name):], nil
+			}
+		}
+	}
+	return 0, value, fmt.Errorf("unknown name")
+}
+
+func parseOffset(value string) (int, string, error) {
+	if value != "" && value[0] == 'Z' {
+		return 0, value[1:], nil
+	}
+	if value == "" || (value[0] != '+' && value[0] != '-') {
+		return 0, value, fmt.Errorf("expected sign")
+	}
+	sign := 1
+	if value[0] == '-' {
+		sign = -1
+	}
+	value = value[1:]
+
+	var parts [3]int
+	n := 0
+	for ; n < 3; n++ {
+		if n > 0 && value != "" && value[0] == ':' {
+			value = value[1:]
+		}
+		if len(value) < 2 || !isDigit(value[0]) || !isDigit(value[1]) {
+			break
+		}
+		parts[n] = int(value[0]-'0')*10 + int(value[1]-'0')
+		value = value[2:]
+	}
+	if n == 0 || parts[0] > 23 || parts[1] > 59 || parts[2] > 59 {
+		return 0, value, fmt.Errorf("invalid offset")
+	}
+	return sign * (parts[0]*3600 + parts[1]*60 + parts[2]), value, nil
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
@@ -0,0 +1,106 @@
+package time
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatUnix(t *testing.T) {
+	tm := time.Date(2006, 1, 2, 3, 4, 5, 123456789, time.FixedZone("MST", -7*3600))
+
+	tests := []struct {
+		layout, expected string
+	}{
+		{"%a %A %b %B %h %c", "Mon Monday Jan January Jan Mon Jan  2 03:04:05 2006"},
+		{"%C %d %e %D %F %g %G", "20 02  2 01/02/06 2006-01-02 06 2006"},
+		{"%H %I %j %k %l %m %M", "03 03 002  3  3 01 04"},
+		{"%p %P %r %R %s %S %T", "AM am 03:04:05 AM 03:04 1136196245 05 03:04:05"},
+		{"%u %U %V %w %W %x %X %y %Y", "1 01 01 1 01 01/02/06 03:04:05 06 2006"},
+		{"%z %:z %::z %Z %%", "-0700 -07:00 -07:00:00 MST %"},
+		{"%-d %_H %-I %3N %N %^a %#p %10A", "2  3 3 123 123456789 MON am     Monday"},
+		{"%+ %n%t", "Mon Jan  2 03:04:05 MST 2006 \n\t"},
+	}
+
+	for _, tt := range tests {
+		if actual := FormatUnix(tm, tt.layout); actual != tt.expected {
+			t.Fatalf("%s: %q != %q", tt.layout, tt.expected, actual)
+		}
+	}
+
+	tm = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	if actual := FormatUnix(tm, "%U %W %V %G %g %j %u %w"); actual != "00 00 53 2020 20 001 5 5" {
+		t.Fatalf("%q != %q", "00 00 53 2020 20 001 5 5", actual)
+	}
+}
+
+func TestParseUnix(t *testing.T) {
+	mst := time.FixedZone("MST", -7*3600)
+	expected := time.Date(2006, 1, 2, 15, 4, 5, 0, mst)
+
+	tests := []struct {
+		layout, value string
+	}{
+		{"%Y-%m-%d %H:%M:%S %z", "2006-01-02 15:04:05 -0700"},
+		{"%F %T %:z", "2006-01-02 15:04:05 -07:00"},
+		{"%a, %d %b %Y %I:%M:%S %p %z", "mon, 02 JAN 2006 03:04:05 pm -0700"},
+		{"%A %B %e %l:%M:%S %P %Y %z", "Monday January  2  3:04:05 pm 2006 -0700"},
+		{"%Y %j %k%M%S %z", "2006 002 150405 -0700"},
+		{"%G-W%V-%u %T %z", "2006-W01-1 15:04:05 -0700"},
+		{"%Y %U %w %T %z", "2006 01 1 15:04:05 -0700"},
+		{"%Y %W %a %T %z", "2006 01 Mon 15:04:05 -0700"},
+		{"%C%y-%m-%d %T %z", "2006-01-02 15:04:05 -0700"},
+		{"%D %T %z", "01/02/06 15:04:05 -0700"},
+		{"%s %z", "1136239445 -0700"},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseUnix(tt.layout, tt.value)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.layout, err)
+		}
+		if !actual.Equal(expected) {
+			t.Fatalf("%s: %s != %s", tt.layout, expected, actual)
+		}
+		if _, offset := actual.Zone(); offset != -7*3600 {
+			t.Fatalf("%s: unexpected offset %d", tt.layout, offset)
+		}
+	}
+
+	actual, err := ParseUnix("%T.%N %z", "15:04:05.123 +0000")
+	if _, offset := actual.Zone(); err != nil || actual.Nanosecond() != 123000000 || offset != 0 {
+		t.Fatalf("unexpected result: %s, %v", actual, err)
+	}
+}
+
+func TestParseUnixError(t *testing.T) {
+	tests := []struct {
+		layout, value string
+	}{
+		{"%Y-%m-%d", "2006-02-30"},
+		{"%Y-%m-%d", "2006-13-01"},
+		{"%H:%M", "24:00"},
+		{"%Y", "2006 extra"},
+		{"%Y-%m", "2006/01"},
+		{"%a", "Foo"},
+		{"%Y %j", "2006 366"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseUnix(tt.layout, tt.value); err == nil {
+			t.Fatalf("%s: expected error parsing %q", tt.layout, tt.value)
+		}
+	}
+}
+
+func TestFormatParseUnixRoundTrip(t *testing.T) {
+	tm := time.Date(2016, 12, 31, 23, 59, 58, 0, time.UTC)
+	for _, layout := range []string{"%c %z", "%+ %z", "%Y%m%d%H%M%S %z", "%G %V %A %r %z"} {
+		actual, err := ParseUnix(layout, FormatUnix(tm, layout))
+		if err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		if !actual.Equal(tm) {
+			t.Fatalf("%s: %s != %s", layout, tm, actual)
+		}
+	}
+}
@@ -4,52 +4,188 @@
 package time
 
 import (
+	"context"
+	"math"
 	"math/rand"
 	"time"
 )
 
+// maxDuration is the largest representable duration.
+const maxDuration = time.Duration(math.MaxInt64)
+
+// defaultInitial is the initial interval of a Ticker whose Backoff has none.
+const defaultInitial = time.Second
+
 // IntervalStrategy defines an interval strategy function.
 type IntervalStrategy func(prev time.Duration) (next time.Duration)
 
+// AttemptStrategy defines an interval strategy function computing the next
+// interval from the previous one and the number of intervals passed since a
+// Ticker was started or reset, or since Retry was called. The first interval
+// is Backoff.Initial, so attempt starts at 1. Being stateless, a strategy can
+// be shared by several tickers and Retry calls.
+type AttemptStrategy func(attempt int, prev time.Duration) (next time.Duration)
+
+// Backoff configures the tick intervals of a Ticker.
+type Backoff struct {
+	// Strategy computes the next interval from the previous one. Defaults to
+	// Linear.
+	Strategy IntervalStrategy
+	// AttemptStrategy computes the next interval from the attempt number and
+	// the previous interval. If set, it is used instead of Strategy.
+	AttemptStrategy AttemptStrategy
+	// Initial is the interval before the first tick and after a Reset. A
+	// Ticker defaults non-positive values to one second, as a zero interval
+	// would tick without pause.
+	Initial time.Duration
+	// Max caps the interval returned by the strategy. Zero means no cap.
+	Max time.Duration
+	// MaxElapsed stops the ticker once this much time has passed since it
+	// was started or last reset. Zero means no limit.
+	MaxElapsed time.Duration
+	// Clock is used to measure time and wait for intervals. Defaults to
+	// RealClock.
+	Clock Clock
+}
+
+// next returns the interval following prev after the given attempt, capped
+// at Max.
+func (b Backoff) next(prev time.Duration, attempt int) time.Duration {
+	var next time.Duration
+	switch {
+	case b.AttemptStrategy != nil:
+		next = b.AttemptStrategy(attempt, prev)
+	case b.Strategy != nil:
+		next = b.Strategy(prev)
+	default:
+		next = Linear(prev)
+	}
+	if next < 0 {
+		next = 0
+	}
+	if b.Max > 0 && next > b.Max {
+		next = b.Max
+	}
+	return next
+}
+
 // Ticker is similar to package time's Ticker but instead of choosing tick
 // interval durations, tick interval strategies and maximum value are chosen.
 type Ticker struct {
-	C      chan time.Time
-	strat  IntervalStrategy
-	prev   time.Duration
-	done   bool
-	doneCh chan struct{}
+	C chan time.Time
+
+	backoff Backoff
+	ctx     context.Context
+	cancel  context.CancelFunc
+	resetCh chan chan struct{}
+	doneCh  chan struct{}
 }
 
 // NewTicker returns a new Ticker containing a channel that will send the time
-// in interval periods specified by an interval strategy. Stop the ticker to
-// release associated resources.
+// in interval periods specified by an interval strategy, starting from an
+// interval of one second. Stop the ticker to release associated resources.
 func NewTicker(strategy IntervalStrategy) *Ticker {
-	c := make(chan time.Time, 1)
+	return NewBackoffTicker(context.Background(), Backoff{Strategy: strategy})
+}
+
+// NewBackoffTicker returns a new Ticker containing a channel that will send
+// the time in interval periods specified by a backoff configuration. The
+// ticker stops when the context is done, when Backoff.MaxElapsed has passed,
+// or when Stop is called, whichever happens first.
+func NewBackoffTicker(ctx context.Context, backoff Backoff) *Ticker {
+	if backoff.Initial <= 0 {
+		backoff.Initial = defaultInitial
+	}
+	ctx, cancel := context.WithCancel(ctx)
 
 	t := &Ticker{
-		C:     c,
-		strat: strategy,
+		C:       make(chan time.Time, 1),
+		backoff: backoff,
+		ctx:     ctx,
+		cancel:  cancel,
+		resetCh: make(chan chan struct{}),
+		doneCh:  make(chan struct{}),
 	}
 
-	go t.start()
+	go t.run()
 	return t
 }
 
-func (t *Ticker) start() {
-	t.prev = t.strat(t.prev)
-	v := <-time.After(t.prev)
-	if !t.done {
-		t.C <- v
-		go t.start()
+func (t *Ticker) run() {
+	defer close(t.doneCh)
+	defer t.cancel()
+
+	clock := clockOrReal(t.backoff.Clock)
+	interval := t.backoff.Initial
+	attempt := 0
+	start := clock.Now()
+	timer := clock.NewTimer(interval)
+	defer timer.Stop()
+
+	reset := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+		interval = t.backoff.Initial
+		attempt = 0
+		start = clock.Now()
+		timer.Reset(interval)
+	}
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case done := <-t.resetCh:
+			reset()
+			close(done)
+		case now := <-timer.C():
+			if t.backoff.MaxElapsed > 0 && now.Sub(start) >= t.backoff.MaxElapsed {
+				return
+			}
+			select {
+			case t.C <- now:
+				attempt++
+				interval = t.backoff.next(interval, attempt)
+				timer.Reset(interval)
+			case done := <-t.resetCh:
+				reset()
+				close(done)
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Reset restarts the ticker from its initial interval, e.g. after an
+// operation succeeded. The next tick is sent once the initial interval has
+// passed after Reset returned. It has no effect on a stopped ticker.
+func (t *Ticker) Reset() {
+	done := make(chan struct{})
+	select {
+	case t.resetCh <- done:
+		<-done
+	case <-t.doneCh:
 	}
 }
 
 // Stop turns off a ticker. After Stop, no more ticks will be sent. Stop does
 // not close the channel, to prevent a read from the channel succeeding
-// incorrectly.
+// incorrectly. Stop is safe to call concurrently and more than once.
 func (t *Ticker) Stop() {
-	t.done = true
+	t.cancel()
+	<-t.doneCh
+}
+
+// Done returns a channel that is closed once the ticker stopped, either
+// because Stop was called, the context is done, or the maximum elapsed time
+// has passed.
+func (t *Ticker) Done() <-chan struct{} {
+	return t.doneCh
 }
 
 // Tick is a convenience wrapper for NewTicker providing access to the ticking
@@ -65,19 +201,77 @@ func Linear(prev time.Duration) time.Duration {
 	return prev
 }
 
-// Exponential defines the strategy of having linearly increasing tick
+// Exponential defines the strategy of having exponentially increasing tick
 // intervals.
 func Exponential(prev time.Duration) time.Duration {
+	if prev > maxDuration/2 {
+		return maxDuration
+	}
 	return 2 * prev
 }
 
 // LinearJitter defines the strategy of having random tick intervals.
 func LinearJitter(prev time.Duration) time.Duration {
+	if prev <= 0 {
+		return 0
+	}
 	return time.Duration(rand.Int63n(int64(prev)))
 }
 
-// ExponentialJitter defines the strategy of having linearly increasing random
-// tick intervals.
+// ExponentialJitter defines the strategy of having exponentially increasing
+// random tick intervals.
 func ExponentialJitter(prev time.Duration) time.Duration {
-	return time.Duration(rand.Int63n(int64(2 * prev)))
+	if prev <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(Exponential(prev))))
+}
+
+// FullJitter returns a strategy of exponentially increasing intervals between
+// base and limit, where each interval is drawn uniformly at random from [0,
+// interval).
+func FullJitter(base, limit time.Duration) AttemptStrategy {
+	return func(attempt int, _ time.Duration) time.Duration {
+		return LinearJitter(exponentialInterval(base, limit, attempt))
+	}
+}
+
+// EqualJitter returns a strategy of exponentially increasing intervals between
+// base and limit, where each interval keeps half of its value and jitters the
+// other half.
+func EqualJitter(base, limit time.Duration) AttemptStrategy {
+	return func(attempt int, _ time.Duration) time.Duration {
+		half := exponentialInterval(base, limit, attempt) / 2
+		return half + LinearJitter(half)
+	}
+}
+
+// DecorrelatedJitter returns a strategy of random intervals between base and
+// three times the previous interval, capped at limit.
+func DecorrelatedJitter(base, limit time.Duration) AttemptStrategy {
+	return func(_ int, prev time.Duration) time.Duration {
+		if prev < base {
+			prev = base
+		}
+		upper := limit
+		if prev <= limit/3 {
+			upper = 3 * prev
+		}
+		if upper <= base {
+			return upper
+		}
+		return base + time.Duration(rand.Int63n(int64(upper-base)))
+	}
+}
+
+// exponentialInterval returns base * 2^(attempt-1) capped at limit.
+func exponentialInterval(base, limit time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d = Exponential(d)
+	}
+	if d > limit {
+		d = limit
+	}
+	return d
 }
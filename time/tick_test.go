@@ -0,0 +1,110 @@
+package time
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackoffTickerMax(t *testing.T) {
+	ticker := NewBackoffTicker(context.Background(), Backoff{
+		Strategy: Exponential,
+		Initial:  time.Millisecond,
+		Max:      4 * time.Millisecond,
+	})
+	defer ticker.Stop()
+
+	prev := time.Now()
+	for i := 0; i < 6; i++ {
+		now := <-ticker.C
+		if d := now.Sub(prev); i > 3 && d > 50*time.Millisecond {
+			t.Fatalf("interval %d not capped: %s", i, d)
+		}
+		prev = now
+	}
+}
+
+func TestBackoffTickerMaxElapsed(t *testing.T) {
+	ticker := NewBackoffTicker(context.Background(), Backoff{
+		Initial:    time.Millisecond,
+		MaxElapsed: 10 * time.Millisecond,
+	})
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case <-ticker.C:
+		case <-ticker.Done():
+			return
+		case <-timeout:
+			t.Fatal("ticker did not stop after max elapsed time")
+		}
+	}
+}
+
+func TestBackoffTickerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := NewBackoffTicker(ctx, Backoff{Initial: time.Hour})
+	cancel()
+
+	select {
+	case <-ticker.Done():
+	case <-time.After(time.Second):
+		t.Fatal("ticker did not stop after context was canceled")
+	}
+	ticker.Reset()
+	ticker.Stop()
+	ticker.Stop()
+}
+
+func TestBackoffTickerReset(t *testing.T) {
+	ticker := NewBackoffTicker(context.Background(), Backoff{
+		Strategy: Exponential,
+		Initial:  time.Millisecond,
+	})
+	defer ticker.Stop()
+
+	for i := 0; i < 8; i++ {
+		<-ticker.C
+	}
+	ticker.Reset()
+
+	select {
+	case <-ticker.C:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("ticker did not restart from initial interval")
+	}
+}
+
+func TestBackoffTickerDefaultInitial(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ticker := NewBackoffTicker(context.Background(), Backoff{Clock: clock})
+	defer ticker.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(defaultInitial - time.Nanosecond)
+	select {
+	case <-ticker.C:
+		t.Fatal("ticker ticked before the default initial interval")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Nanosecond)
+	select {
+	case <-ticker.C:
+	case <-time.After(time.Second):
+		t.Fatal("ticker did not tick after the default initial interval")
+	}
+}
+
+func TestJitterZero(t *testing.T) {
+	if d := LinearJitter(0); d != 0 {
+		t.Fatalf("0 != %s", d)
+	}
+	if d := ExponentialJitter(0); d != 0 {
+		t.Fatalf("0 != %s", d)
+	}
+	if d := Exponential(maxDuration); d != maxDuration {
+		t.Fatalf("%s != %s", maxDuration, d)
+	}
+}
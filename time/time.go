@@ -2,74 +2,30 @@ package time
 
 import (
 	"encoding/xml"
+	"strconv"
 	"strings"
 	"time"
 )
 
-var formatReplacer *strings.Replacer
-var formatPatterns = map[string]string{
-	// Day
-	"%d": "02",     // Two-digit day of the month (with leading zeros)
-	"%e": "2",      // Day of the month, with a single digits
-	"%a": "Mon",    // An abbreviated textual representation of the day
-	"%A": "Monday", // A full textual representation of the day
-	// Month
-	"%b": "Jan",     // Abbreviated month name
-	"%h": "Jan",     // Abbreviated month name (an alias of %b)
-	"%B": "January", // Full month name
-	"%m": "01",      // Two digit representation of the month
-	// Year
-	"%y": "06",   // Two digit representation of the year
-	"%Y": "2006", // Four digit representation for the year
-	// Time
-	"%H": "15",          // Two digit representation of the hour in 24-hour format
-	"%k": "15",          // Two digit representation of the hour in 24-hour format, with a space preceding single digits
-	"%I": "03",          // Two digit representation of the hour in 12-hour format
-	"%l": "3",           // Hour in 12-hour format, with a space preceding single digits
-	"%M": "04",          // Two digit representation of the minute
-	"%P": "pm",          // UPPER-CASE 'AM' or 'PM' based on the given time
-	"%p": "PM",          // lower-case 'am' or 'pm' based on the given time
-	"%r": "03:04:05 PM", // Same as "%I:%M:%S %p"
-	"%R": "15:04",       // Same as "%H:%M"
-	"%S": "05",          // Two digit representation of the second
-	"%T": "15:04:05",    // Same as "%H:%M:%S"
-	"%z": "-0700",       // The time zone offset. Not implemented as described on Windows. See below for more information.
-	"%Z": "MST",         // The time zone abbreviation.
-	// Time and Date Stamps
-	"%D": "01/02/2006", // Same as "%m/%d/%y"
-	"%F": "2006-01-02", // Same as "%Y-%m-%d"
-	// Miscellaneous
-	"%n": "\n", // A newline character ("\n")
-	"%t": "\t", // A Tab character ("\t")
-	"%%": "%",  // A literal percentage character ("%")
-}
-
-func init() {
-	var fp []string
-	for k, v := range formatPatterns {
-		fp = append(fp, k, v)
-	}
-	formatReplacer = strings.NewReplacer(fp...)
-}
-
 // ParseUnix parses a formatted string and returns the time value it represents.
 // The layout defines the format declared in
-// http://pubs.opengroup.org/onlinepubs/007908799/xsh/strftime.html . The
-// implementation differs from the original as no local information is taken
-// into account.
+// http://pubs.opengroup.org/onlinepubs/007908799/xsh/strftime.html including
+// the GNU and BSD extensions %k, %l, %s, %N, %P, %:z, %+ and the padding flags
+// -, _ and 0. The implementation differs from the original as no locale information is
+// taken into account. In the absence of time zone information, the value is
+// interpreted in the local time zone.
 func ParseUnix(layout, value string) (time.Time, error) {
-	layout = formatReplacer.Replace(layout)
-	return time.ParseInLocation(layout, value, time.Local)
+	return strptime(layout, value, time.Local)
 }
 
 // FormatUnix returns a textual representation of the time value formatted
 // according to layout, which defines the format declared in
-// http://pubs.opengroup.org/onlinepubs/007908799/xsh/strftime.html . The
-// implementation differs from the original as no local information is taken
-// into account.
+// http://pubs.opengroup.org/onlinepubs/007908799/xsh/strftime.html including
+// the GNU and BSD extensions %k, %l, %s, %N, %P, %:z, %+ and the flags -, _, 0,
+// ^ and #. The implementation differs from the original as no locale information is
+// taken into account; output equals the POSIX locale's.
 func FormatUnix(t time.Time, layout string) string {
-	layout = formatReplacer.Replace(layout)
-	return t.Format(layout)
+	return strftime(t, layout)
 }
 
 // RFC3339Time allows RFC 3339 compliant un/marshaling.
@@ -174,3 +130,375 @@ func (t RFC3339Time) GobEncode() ([]byte, error) {
 func (t *RFC3339Time) GobDecode(data []byte) error {
 	return t.UnmarshalBinary(data)
 }
+
+// RFC3339NanoTime allows RFC 3339 compliant un/marshaling with nanosecond
+// precision.
+type RFC3339NanoTime struct {
+	time.Time
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t RFC3339NanoTime) MarshalJSON() ([]byte, error) {
+	b := make([]byte, 0, len(time.RFC3339Nano)+2)
+	b = append(b, '"')
+	b = t.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, '"')
+	return b, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *RFC3339NanoTime) UnmarshalJSON(json []byte) error {
+	parse, err := time.Parse(`"`+time.RFC3339Nano+`"`, string(json))
+	if err != nil {
+		return err
+	}
+	t.Time = parse
+	return nil
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (t RFC3339NanoTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(t.Time.Format(time.RFC3339Nano), start)
+}
+
+// MarshalXMLAttr implements the xml.MarshalerAttr interface.
+func (t RFC3339NanoTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	attr := xml.Attr{
+		Name:  name,
+		Value: t.Time.Format(time.RFC3339Nano),
+	}
+	return attr, nil
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (t *RFC3339NanoTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v string
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	parse, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return err
+	}
+	t.Time = parse
+	return nil
+}
+
+// UnmarshalXMLAttr implements the xml.UnmarshalerAttr interface.
+func (t *RFC3339NanoTime) UnmarshalXMLAttr(attr xml.Attr) error {
+	parse, err := time.Parse(time.RFC3339Nano, attr.Value)
+	if err != nil {
+		return err
+	}
+	t.Time = parse
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t RFC3339NanoTime) MarshalBinary() ([]byte, error) {
+	return []byte(t.Time.Format(time.RFC3339Nano)), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *RFC3339NanoTime) UnmarshalBinary(data []byte) error {
+	parse, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		return err
+	}
+	t.Time = parse
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t RFC3339NanoTime) MarshalText() ([]byte, error) {
+	return []byte(t.Time.Format(time.RFC3339Nano)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *RFC3339NanoTime) UnmarshalText(text []byte) error {
+	parse, err := time.Parse(time.RFC3339Nano, string(text))
+	if err != nil {
+		return err
+	}
+	t.Time = parse
+	return nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (t RFC3339NanoTime) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (t *RFC3339NanoTime) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// UnixTime allows un/marshaling as seconds since the Unix epoch.
+type UnixTime struct {
+	time.Time
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, t.Unix(), 10), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *UnixTime) UnmarshalJSON(json []byte) error {
+	if string(json) == "null" {
+		return nil
+	}
+	return t.UnmarshalText(json)
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (t UnixTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(t.Unix(), start)
+}
+
+// MarshalXMLAttr implements the xml.MarshalerAttr interface.
+func (t UnixTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	attr := xml.Attr{
+		Name:  name,
+		Value: strconv.FormatInt(t.Unix(), 10),
+	}
+	return attr, nil
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (t *UnixTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v string
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	return t.UnmarshalText([]byte(v))
+}
+
+// UnmarshalXMLAttr implements the xml.UnmarshalerAttr interface.
+func (t *UnixTime) UnmarshalXMLAttr(attr xml.Attr) error {
+	return t.UnmarshalText([]byte(attr.Value))
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t UnixTime) MarshalBinary() ([]byte, error) {
+	return t.MarshalText()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *UnixTime) UnmarshalBinary(data []byte) error {
+	return t.UnmarshalText(data)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t UnixTime) MarshalText() ([]byte, error) {
+	return strconv.AppendInt(nil, t.Unix(), 10), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *UnixTime) UnmarshalText(text []byte) error {
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(text)), 10, 64)
+	if err != nil {
+		return err
+	}
+	t.Time = time.Unix(sec, 0)
+	return nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (t UnixTime) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (t *UnixTime) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// UnixMilliTime allows un/marshaling as milliseconds since the Unix epoch.
+type UnixMilliTime struct {
+	time.Time
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t UnixMilliTime) MarshalJSON() ([]byte, error) {
+	return t.MarshalText()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *UnixMilliTime) UnmarshalJSON(json []byte) error {
+	if string(json) == "null" {
+		return nil
+	}
+	return t.UnmarshalText(json)
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (t UnixMilliTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(unixMilli(t.Time), start)
+}
+
+// MarshalXMLAttr implements the xml.MarshalerAttr interface.
+func (t UnixMilliTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	attr := xml.Attr{
+		Name:  name,
+		Value: strconv.FormatInt(unixMilli(t.Time), 10),
+	}
+	return attr, nil
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (t *UnixMilliTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v string
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	return t.UnmarshalText([]byte(v))
+}
+
+// UnmarshalXMLAttr implements the xml.UnmarshalerAttr interface.
+func (t *UnixMilliTime) UnmarshalXMLAttr(attr xml.Attr) error {
+	return t.UnmarshalText([]byte(attr.Value))
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t UnixMilliTime) MarshalBinary() ([]byte, error) {
+	return t.MarshalText()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *UnixMilliTime) UnmarshalBinary(data []byte) error {
+	return t.UnmarshalText(data)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t UnixMilliTime) MarshalText() ([]byte, error) {
+	return strconv.AppendInt(nil, unixMilli(t.Time), 10), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *UnixMilliTime) UnmarshalText(text []byte) error {
+	msec, err := strconv.ParseInt(strings.TrimSpace(string(text)), 10, 64)
+	if err != nil {
+		return err
+	}
+	t.Time = time.Unix(msec/1000, msec%1000*int64(time.Millisecond))
+	return nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (t UnixMilliTime) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (t *UnixMilliTime) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// NullTime allows RFC 3339 compliant un/marshaling of optional times. Invalid
+// times are marshaled as JSON null or empty strings; JSON null and empty
+// strings are unmarshaled as invalid times.
+type NullTime struct {
+	time.Time
+	Valid bool
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t NullTime) MarshalJSON() ([]byte, error) {
+	if !t.Valid {
+		return []byte("null"), nil
+	}
+	return RFC3339NanoTime{t.Time}.MarshalJSON()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *NullTime) UnmarshalJSON(json []byte) error {
+	if s := string(json); s == "null" || s == `""` {
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	}
+	parse, err := time.Parse(`"`+time.RFC3339Nano+`"`, string(json))
+	if err != nil {
+		return err
+	}
+	t.Time, t.Valid = parse, true
+	return nil
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (t NullTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	text, _ := t.MarshalText()
+	return e.EncodeElement(string(text), start)
+}
+
+// MarshalXMLAttr implements the xml.MarshalerAttr interface.
+func (t NullTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	text, _ := t.MarshalText()
+	attr := xml.Attr{
+		Name:  name,
+		Value: string(text),
+	}
+	return attr, nil
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (t *NullTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v string
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	return t.UnmarshalText([]byte(v))
+}
+
+// UnmarshalXMLAttr implements the xml.UnmarshalerAttr interface.
+func (t *NullTime) UnmarshalXMLAttr(attr xml.Attr) error {
+	return t.UnmarshalText([]byte(attr.Value))
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t NullTime) MarshalBinary() ([]byte, error) {
+	return t.MarshalText()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *NullTime) UnmarshalBinary(data []byte) error {
+	return t.UnmarshalText(data)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t NullTime) MarshalText() ([]byte, error) {
+	if !t.Valid {
+		return []byte{}, nil
+	}
+	return []byte(t.Time.Format(time.RFC3339Nano)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *NullTime) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	}
+	parse, err := time.Parse(time.RFC3339Nano, string(text))
+	if err != nil {
+		return err
+	}
+	t.Time, t.Valid = parse, true
+	return nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (t NullTime) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (t *NullTime) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// unixMilli returns t as milliseconds since the Unix epoch.
+func unixMilli(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
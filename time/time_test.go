@@ -0,0 +1,99 @@
+package time
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestUnixTimeJSON(t *testing.T) {
+	v := struct {
+		S UnixTime      `json:"s"`
+		M UnixMilliTime `json:"m"`
+		N RFC3339NanoTime
+	}{}
+	tm := time.Unix(1136239445, 123456789).UTC()
+	v.S.Time, v.M.Time, v.N.Time = tm, tm, tm
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"s":1136239445,"m":1136239445123,"N":"2006-01-02T22:04:05.123456789Z"}`
+	if string(data) != expected {
+		t.Fatalf("%s != %s", expected, data)
+	}
+
+	v.S, v.M, v.N = UnixTime{}, UnixMilliTime{}, RFC3339NanoTime{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.S.Unix() != 1136239445 || !v.M.Equal(tm.Truncate(time.Millisecond)) || !v.N.Equal(tm) {
+		t.Fatalf("unexpected result: %+v", v)
+	}
+}
+
+func TestNullTimeJSON(t *testing.T) {
+	var v struct{ T NullTime }
+	for _, data := range []string{`{"T":null}`, `{"T":""}`} {
+		v.T = NullTime{time.Now(), true}
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.T.Valid || !v.T.IsZero() {
+			t.Fatalf("%s: unexpected result %+v", data, v.T)
+		}
+	}
+	data, _ := json.Marshal(v)
+	if string(data) != `{"T":null}` {
+		t.Fatalf(`{"T":null} != %s`, data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"T":"2006-01-02T15:04:05Z"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !v.T.Valid || v.T.Unix() != 1136214245 {
+		t.Fatalf("unexpected result %+v", v.T)
+	}
+}
+
+func TestTimeXMLGob(t *testing.T) {
+	type doc struct {
+		A UnixMilliTime `xml:"a,attr"`
+		S UnixTime      `xml:"s"`
+		N NullTime      `xml:"n"`
+	}
+	tm := time.Unix(1136239445, 0)
+	in := doc{UnixMilliTime{tm}, UnixTime{tm}, NullTime{}}
+
+	data, err := xml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<doc a="1136239445000"><s>1136239445</s><n></n></doc>`
+	if string(data) != expected {
+		t.Fatalf("%s != %s", expected, data)
+	}
+	var out doc
+	if err := xml.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.A.Equal(tm) || !out.S.Equal(tm) || out.N.Valid {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	out = doc{}
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.A.Equal(tm) || !out.S.Equal(tm) || out.N.Valid {
+		t.Fatalf("unexpected result %+v", out)
+	}
+}